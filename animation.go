@@ -0,0 +1,289 @@
+package glutils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// VectorKey is a position or scale keyframe, at Time seconds.
+type VectorKey struct {
+	Time  float32
+	Value mgl32.Vec3
+}
+
+// QuatKey is a rotation keyframe, at Time seconds.
+type QuatKey struct {
+	Time  float32
+	Value mgl32.Quat
+}
+
+// AnimationChannel animates the local transform of the node named Node. Keys
+// are sorted by time, a component without keys keeps its rest value.
+type AnimationChannel struct {
+	Node      string
+	Positions []VectorKey
+	Rotations []QuatKey
+	Scales    []VectorKey
+}
+
+// AnimationClip is a named animation of Duration seconds.
+type AnimationClip struct {
+	Name     string
+	Duration float32
+	Channels []AnimationChannel
+}
+
+// transform is a local transform split into translation, rotation and scale
+// so that it can be interpolated.
+type transform struct {
+	translation mgl32.Vec3
+	rotation    mgl32.Quat
+	scale       mgl32.Vec3
+}
+
+func decompose(m mgl32.Mat4) transform {
+	x := transform{translation: m.Col(3).Vec3()}
+	var r mgl32.Mat3
+	for i := 0; i < 3; i++ {
+		c := m.Col(i).Vec3()
+		x.scale[i] = c.Len()
+		if x.scale[i] != 0 {
+			c = c.Mul(1 / x.scale[i])
+		}
+		r.SetCol(i, c)
+	}
+	x.rotation = mgl32.Mat4ToQuat(r.Mat4()).Normalize()
+	return x
+}
+
+func (x transform) Mat4() mgl32.Mat4 {
+	return mgl32.Translate3D(x.translation[0], x.translation[1], x.translation[2]).
+		Mul4(x.rotation.Mat4()).
+		Mul4(mgl32.Scale3D(x.scale[0], x.scale[1], x.scale[2]))
+}
+
+// search returns the index of the first of n keys after t.
+func search(n int, t float32, time func(int) float32) int {
+	return sort.Search(n, func(i int) bool { return time(i) > t })
+}
+
+func sampleVector(keys []VectorKey, t float32, rest mgl32.Vec3) mgl32.Vec3 {
+	if len(keys) == 0 {
+		return rest
+	}
+	i := search(len(keys), t, func(i int) float32 { return keys[i].Time })
+	switch {
+	case i == 0:
+		return keys[0].Value
+	case i == len(keys):
+		return keys[i-1].Value
+	}
+	a, b := keys[i-1], keys[i]
+	f := (t - a.Time) / (b.Time - a.Time)
+	return a.Value.Add(b.Value.Sub(a.Value).Mul(f))
+}
+
+func sampleQuat(keys []QuatKey, t float32, rest mgl32.Quat) mgl32.Quat {
+	if len(keys) == 0 {
+		return rest
+	}
+	i := search(len(keys), t, func(i int) float32 { return keys[i].Time })
+	switch {
+	case i == 0:
+		return keys[0].Value
+	case i == len(keys):
+		return keys[i-1].Value
+	}
+	a, b := keys[i-1], keys[i]
+	return mgl32.QuatSlerp(a.Value, b.Value, (t-a.Time)/(b.Time-a.Time))
+}
+
+// sample returns the transform of the channel at t seconds.
+func (c *AnimationChannel) sample(t float32, rest transform) transform {
+	return transform{
+		translation: sampleVector(c.Positions, t, rest.translation),
+		rotation:    sampleQuat(c.Rotations, t, rest.rotation),
+		scale:       sampleVector(c.Scales, t, rest.scale),
+	}
+}
+
+// Sample returns the local transform of the channel at t seconds, the
+// components without keys taken from rest.
+func (c *AnimationChannel) Sample(t float32, rest mgl32.Mat4) mgl32.Mat4 {
+	return c.sample(t, decompose(rest)).Mat4()
+}
+
+// Animation returns the clip named name, nil if there is none.
+func (m *Model) Animation(name string) *AnimationClip {
+	for i := range m.Animations {
+		if m.Animations[i].Name == name {
+			return &m.Animations[i]
+		}
+	}
+	return nil
+}
+
+// AnimationLayer is a clip played by an Animator. Layers are blended by
+// Weight.
+type AnimationLayer struct {
+	Clip   *AnimationClip
+	Time   float32
+	Speed  float32
+	Weight float32
+	Loop   bool
+	// fade is the change of Weight per second.
+	fade     float32
+	channels map[string]*AnimationChannel
+}
+
+// Animator poses the skeleton of a model by blending animation clips, and
+// computes the bone palette to upload to the shader, for instance with
+// Shader.SetMat4Array.
+type Animator struct {
+	Layers   []*AnimationLayer
+	root     *Node
+	skeleton *Skeleton
+	rest     map[*Node]transform
+	palette  []mgl32.Mat4
+}
+
+// NewAnimator returns an animator of the node tree and skeleton of m, in its
+// rest pose.
+func NewAnimator(m *Model) (*Animator, error) {
+	if m.Skeleton == nil || len(m.Skeleton.Bones) == 0 {
+		return nil, fmt.Errorf("model %s has no skeleton", m.FileName)
+	}
+	a := &Animator{
+		root:     m.root(),
+		skeleton: m.Skeleton,
+		rest:     make(map[*Node]transform),
+		palette:  make([]mgl32.Mat4, len(m.Skeleton.Bones)),
+	}
+	a.root.Walk(mgl32.Ident4(), func(n *Node, _ mgl32.Mat4) {
+		a.rest[n] = decompose(n.Transform)
+	})
+	a.Update(0)
+	return a, nil
+}
+
+// Play adds a layer playing clip with weight.
+func (a *Animator) Play(clip *AnimationClip, weight float32, loop bool) *AnimationLayer {
+	l := &AnimationLayer{
+		Clip:     clip,
+		Speed:    1,
+		Weight:   weight,
+		Loop:     loop,
+		channels: make(map[string]*AnimationChannel, len(clip.Channels)),
+	}
+	for i := range clip.Channels {
+		l.channels[clip.Channels[i].Node] = &clip.Channels[i]
+	}
+	a.Layers = append(a.Layers, l)
+	return l
+}
+
+// CrossFade fades the playing layers out and clip in over duration seconds.
+// Faded out layers are removed.
+func (a *Animator) CrossFade(clip *AnimationClip, duration float32, loop bool) *AnimationLayer {
+	if duration <= 0 {
+		a.Layers = a.Layers[:0]
+		return a.Play(clip, 1, loop)
+	}
+	for _, l := range a.Layers {
+		l.fade = -l.Weight / duration
+	}
+	l := a.Play(clip, 0, loop)
+	l.fade = 1 / duration
+	return l
+}
+
+// Update advances the layers by dt seconds and poses the skeleton.
+func (a *Animator) Update(dt float32) {
+	layers := a.Layers[:0]
+	for _, l := range a.Layers {
+		l.Time += dt * l.Speed
+		if d := l.Clip.Duration; l.Loop && d > 0 {
+			l.Time = float32(math.Mod(float64(l.Time), float64(d)))
+			if l.Time < 0 {
+				l.Time += d
+			}
+		} else if l.Time > d {
+			l.Time = d
+		} else if l.Time < 0 {
+			l.Time = 0
+		}
+		if l.fade != 0 {
+			l.Weight += l.fade * dt
+			if l.Weight >= 1 {
+				l.Weight, l.fade = 1, 0
+			}
+			if l.Weight <= 0 {
+				continue
+			}
+		}
+		layers = append(layers, l)
+	}
+	for i := len(layers); i < len(a.Layers); i++ {
+		a.Layers[i] = nil
+	}
+	a.Layers = layers
+
+	for i := range a.palette {
+		a.palette[i] = mgl32.Ident4()
+	}
+	globalInverse := a.root.Transform.Inv()
+	a.pose(a.root, mgl32.Ident4(), globalInverse)
+}
+
+func (a *Animator) pose(n *Node, parent, globalInverse mgl32.Mat4) {
+	world := parent.Mul4(a.local(n))
+	if i, ok := a.skeleton.BoneIndex(n.Name); ok {
+		a.palette[i] = globalInverse.Mul4(world).Mul4(a.skeleton.Bones[i].InverseBind)
+	}
+	for _, c := range n.Children {
+		a.pose(c, world, globalInverse)
+	}
+}
+
+// local returns the blended local transform of n.
+func (a *Animator) local(n *Node) mgl32.Mat4 {
+	rest := a.rest[n]
+	var (
+		x        transform
+		total    float32
+		animated bool
+	)
+	for _, l := range a.Layers {
+		if l.Weight <= 0 {
+			continue
+		}
+		s := rest
+		if c, ok := l.channels[n.Name]; ok {
+			s, animated = c.sample(l.Time, rest), true
+		}
+		// rotations of opposite signs are the same, blend in the same hemisphere
+		if total > 0 && x.rotation.Dot(s.rotation) < 0 {
+			s.rotation = s.rotation.Scale(-1)
+		}
+		x.translation = x.translation.Add(s.translation.Mul(l.Weight))
+		x.rotation = x.rotation.Add(s.rotation.Scale(l.Weight))
+		x.scale = x.scale.Add(s.scale.Mul(l.Weight))
+		total += l.Weight
+	}
+	if !animated {
+		return n.Transform
+	}
+	x.translation = x.translation.Mul(1 / total)
+	x.rotation = x.rotation.Normalize()
+	x.scale = x.scale.Mul(1 / total)
+	return x.Mat4()
+}
+
+// Palette returns the skinning matrices of the bones, indexed by
+// Vertex.BoneIDs.
+func (a *Animator) Palette() []mgl32.Mat4 {
+	return a.palette
+}
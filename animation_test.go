@@ -0,0 +1,53 @@
+package glutils
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// TestPaletteNonRootMesh poses a bone of a skinned mesh whose node is not at
+// the root transform, the node must not move the skinned vertices.
+func TestPaletteNonRootMesh(t *testing.T) {
+	root := NewNode("root", mgl32.Scale3D(2, 2, 2))
+	body := NewNode("body", mgl32.Translate3D(5, 0, 0))
+	body.Meshes = []int{0}
+	bone := NewNode("bone", mgl32.Translate3D(1, 0, 0))
+	root.AddChild(body)
+	root.AddChild(bone)
+
+	vertex := Vertex{Position: mgl32.Vec3{1, 1, 0}}
+	vertex.AddBoneWeight(0, 1)
+	mesh := NewMesh([]Vertex{vertex}, []uint32{0}, nil)
+	mesh.skinned = hasBoneWeights(mesh.Vertices)
+
+	skeleton := &Skeleton{}
+	skeleton.AddBone("bone", bone.Transform.Inv())
+	m := &Model{Meshes: []Mesh{mesh}, Root: root, Skeleton: skeleton}
+	a, err := NewAnimator(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transform := mgl32.Translate3D(0, 0, -3)
+	skin := func() mgl32.Vec3 {
+		model := m.Meshes[0].placement(transform, transform.Mul4(body.WorldMatrix()))
+		return mgl32.TransformCoordinate(vertex.Position, model.Mul4(a.Palette()[0]))
+	}
+	if got := skin(); !got.ApproxEqual(mgl32.Vec3{1, 1, -3}) {
+		t.Errorf("rest pose vertex at %v, want [1 1 -3]", got)
+	}
+
+	bone.Transform = mgl32.Translate3D(1, 2, 0)
+	a.Update(0)
+	if got := skin(); !got.ApproxEqual(mgl32.Vec3{1, 3, -3}) {
+		t.Errorf("posed vertex at %v, want [1 3 -3]", got)
+	}
+
+	// meshes without bones are placed by their node
+	rigid := NewMesh([]Vertex{{Position: mgl32.Vec3{1, 1, 0}}}, []uint32{0}, nil)
+	world := transform.Mul4(body.WorldMatrix())
+	if got := rigid.placement(transform, world); got != world {
+		t.Errorf("rigid mesh placed by %v, want the node world matrix", got)
+	}
+}
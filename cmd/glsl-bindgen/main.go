@@ -0,0 +1,422 @@
+// Command glsl-bindgen generates typed Go bindings for a GLSL program.
+//
+// It runs the program's shader files through the glutils Preprocessor, reads
+// their declarations and writes a Go type embedding glutils.Shader with one
+// setter per uniform, constants for the vertex inputs declared with a layout
+// location, an AttributesMap constructor for an interleaved VertexArray
+// holding the float vertex inputs, and a VertexLayout holding every vertex
+// input, integer ones included. File paths are written relative to the
+// directory of the output file.
+//
+// Typical use is with go generate, next to the shader files:
+//
+//	//go:generate glsl-bindgen -type Basic -vs basic.vs -frag basic.frag -o basic_gen.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/raedatoui/glutils"
+)
+
+type stage struct {
+	Flag, File string
+}
+
+type setter struct {
+	Method, GoType, Call, Name, Comment string
+}
+
+type attribute struct {
+	Const, Name string
+	Location    int
+	Size        int
+	// Offset is in floats in the AttributesMap, -1 for integer inputs.
+	Offset int
+	// Type and ByteOffset describe the input in the VertexLayout.
+	Type       string
+	Integer    bool
+	ByteOffset int
+}
+
+type program struct {
+	Package    string
+	Type       string
+	Files      []string
+	Vert, Frag string
+	Geom       string
+	Setters    []setter
+	Skipped    []string
+	Attributes []attribute
+	Stride     int
+	ByteStride int
+	Floats     bool
+	Locations  bool
+	NeedsMgl   bool
+}
+
+// includes is the -I flag, which can be repeated.
+type includes []string
+
+func (i *includes) String() string { return strings.Join(*i, ",") }
+
+func (i *includes) Set(dir string) error {
+	*i = append(*i, dir)
+	return nil
+}
+
+// options are the command line flags.
+type options struct {
+	Type, Package    string
+	Vert, Frag, Geom string
+	// Out is the output file, file paths are written relative to its
+	// directory.
+	Out      string
+	Includes []string
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("glsl-bindgen: ")
+
+	var (
+		typeName = flag.String("type", "", "name of the generated Go type (required)")
+		vs       = flag.String("vs", "", "vertex shader file (required)")
+		frag     = flag.String("frag", "", "fragment shader file (required)")
+		geom     = flag.String("geom", "", "geometry shader file")
+		pkg      = flag.String("package", os.Getenv("GOPACKAGE"), "package of the generated file")
+		out      = flag.String("o", "", "output file, stdout when empty")
+		incs     includes
+	)
+	flag.Var(&incs, "I", "include path of the shader preprocessor, can be repeated")
+	flag.Parse()
+	if *typeName == "" || *vs == "" || *frag == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *pkg == "" {
+		*pkg = "main"
+	}
+
+	code, err := generate(options{
+		Type:     *typeName,
+		Package:  *pkg,
+		Vert:     *vs,
+		Frag:     *frag,
+		Geom:     *geom,
+		Out:      *out,
+		Includes: incs,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(code)
+		return
+	}
+	if err := ioutil.WriteFile(*out, code, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns the formatted bindings of a program.
+func generate(o options) ([]byte, error) {
+	// paths are embedded relative to the package of the generated file
+	pkgDir := "."
+	if o.Out != "" {
+		pkgDir = filepath.Dir(o.Out)
+	}
+	pkgDir, err := filepath.Abs(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+	rel := func(file string) (string, error) {
+		if file == "" {
+			return "", nil
+		}
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return "", err
+		}
+		r, err := filepath.Rel(pkgDir, abs)
+		if err != nil {
+			return "", err
+		}
+		r = filepath.ToSlash(r)
+		if strings.HasPrefix(r, "../") {
+			log.Printf("warning: %s is outside of the package directory, New%sFS won't find it", file, o.Type)
+		}
+		return r, nil
+	}
+
+	p := program{
+		Package: o.Package,
+		Type:    o.Type,
+	}
+	if p.Vert, err = rel(o.Vert); err != nil {
+		return nil, err
+	}
+	if p.Frag, err = rel(o.Frag); err != nil {
+		return nil, err
+	}
+	if p.Geom, err = rel(o.Geom); err != nil {
+		return nil, err
+	}
+
+	pp := glutils.NewPreprocessor(o.Includes...)
+	seen := make(map[string]bool)
+	seenFiles := make(map[string]bool)
+	for _, st := range []stage{{"vs", o.Vert}, {"geom", o.Geom}, {"frag", o.Frag}} {
+		if st.File == "" {
+			continue
+		}
+		// declarations in #include'd files are part of the program
+		source, err := pp.Process(st.File)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range source.Files {
+			f, err := rel(f)
+			if err != nil {
+				return nil, err
+			}
+			if !seenFiles[f] {
+				seenFiles[f] = true
+				p.Files = append(p.Files, f)
+			}
+		}
+		src := source.Source
+
+		for _, u := range ParseUniforms(src) {
+			if seen[u.Name] {
+				continue
+			}
+			seen[u.Name] = true
+			s, ok := uniformSetter(u)
+			if !ok {
+				p.Skipped = append(p.Skipped, fmt.Sprintf("%s %s", u.Type, u.Name))
+				continue
+			}
+			if strings.HasPrefix(s.GoType, "mgl32.") {
+				p.NeedsMgl = true
+			}
+			p.Setters = append(p.Setters, s)
+		}
+
+		if st.Flag == "vs" {
+			offset := 0
+			for _, in := range ParseInputs(src) {
+				size, xtype := attributeSize(in.Type)
+				if size == 0 {
+					p.Skipped = append(p.Skipped, fmt.Sprintf("in %s %s", in.Type, in.Name))
+					continue
+				}
+				if in.Location >= 0 {
+					p.Locations = true
+				}
+				a := attribute{
+					Const:      p.Type + exported(in.Name) + "Location",
+					Name:       in.Name,
+					Location:   in.Location,
+					Size:       size,
+					Offset:     -1,
+					Type:       xtype,
+					Integer:    xtype != "FLOAT",
+					ByteOffset: p.ByteStride,
+				}
+				// AttributesMap buffers are floats, integer inputs need the
+				// VertexLayout
+				if !a.Integer {
+					a.Offset = offset
+					offset += size
+					p.Floats = true
+				}
+				p.ByteStride += size * 4
+				p.Attributes = append(p.Attributes, a)
+			}
+			p.Stride = offset
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := bindings.Execute(&buf, p); err != nil {
+		return nil, err
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v\n%s", err, buf.Bytes())
+	}
+	return code, nil
+}
+
+// uniformSetter maps a uniform to the glutils.Shader setter for its type.
+func uniformSetter(u Uniform) (setter, bool) {
+	if u.ArraySize > 0 {
+		return setter{}, false
+	}
+	s := setter{Method: "Set" + exported(u.Name), Name: u.Name}
+	switch {
+	case u.Type == "float":
+		s.GoType, s.Call = "float32", "SetFloat"
+	case u.Type == "int":
+		s.GoType, s.Call = "int32", "SetInt"
+	case u.Type == "bool":
+		s.GoType, s.Call = "bool", "SetBool"
+	case u.Type == "vec2":
+		s.GoType, s.Call = "mgl32.Vec2", "SetVec2"
+	case u.Type == "vec3":
+		s.GoType, s.Call = "mgl32.Vec3", "SetVec3"
+	case u.Type == "vec4":
+		s.GoType, s.Call = "mgl32.Vec4", "SetVec4"
+	case u.Type == "mat3":
+		s.GoType, s.Call = "mgl32.Mat3", "SetMat3"
+	case u.Type == "mat4":
+		s.GoType, s.Call = "mgl32.Mat4", "SetMat4"
+	case strings.Contains(u.Type, "sampler"):
+		s.GoType, s.Call = "int32", "SetInt"
+		s.Comment = fmt.Sprintf("binds the %s %s to a texture unit.", u.Type, u.Name)
+	default:
+		return s, false
+	}
+	if s.Comment == "" {
+		s.Comment = fmt.Sprintf("sets the %s %s.", u.Type, u.Name)
+	}
+	return s, true
+}
+
+// attributeSize returns the number of components of a vertex input type and
+// the name of its GL component type, 0 for unsupported types.
+func attributeSize(t string) (int, string) {
+	xtype := "FLOAT"
+	switch {
+	case t == "int" || strings.HasPrefix(t, "ivec"):
+		xtype = "INT"
+	case t == "uint" || strings.HasPrefix(t, "uvec"):
+		xtype = "UNSIGNED_INT"
+	}
+	switch t {
+	case "float", "int", "uint":
+		return 1, xtype
+	case "vec2", "ivec2", "uvec2":
+		return 2, xtype
+	case "vec3", "ivec3", "uvec3":
+		return 3, xtype
+	case "vec4", "ivec4", "uvec4":
+		return 4, xtype
+	}
+	return 0, ""
+}
+
+// exported turns a GLSL identifier into an exported Go identifier:
+// texCoord -> TexCoord, light_dir -> LightDir.
+func exported(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var bindings = template.Must(template.New("bindings").Parse(`// Code generated by glsl-bindgen from {{range $i, $f := .Files}}{{if $i}}, {{end}}{{$f}}{{end}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"io/fs"
+
+	{{if .Attributes}}"github.com/go-gl/gl/v4.1-core/gl"
+	{{end}}{{if .NeedsMgl}}"github.com/go-gl/mathgl/mgl32"
+	{{end}}"github.com/raedatoui/glutils"
+)
+{{$type := .Type}}
+{{- if .Locations}}
+// Vertex input locations declared with a layout qualifier in {{.Vert}}.
+const (
+{{- range .Attributes}}{{if ge .Location 0}}
+	{{.Const}} uint32 = {{.Location}}
+{{- end}}{{end}}
+)
+{{end}}
+// {{.Type}} is the program built from {{range $i, $f := .Files}}{{if $i}}, {{end}}{{$f}}{{end}}.
+{{- if .Skipped}}
+// No setters were generated for:
+{{- range .Skipped}}
+//	{{.}}
+{{- end}}
+{{- end}}
+type {{.Type}} struct {
+	glutils.Shader
+}
+
+// New{{.Type}} compiles the program from its files.
+func New{{.Type}}() (*{{.Type}}, error) {
+	s, err := glutils.NewShader({{printf "%q" .Vert}}, {{printf "%q" .Frag}}, {{printf "%q" .Geom}})
+	if err != nil {
+		return nil, err
+	}
+	return &{{.Type}}{s}, nil
+}
+
+// New{{.Type}}FS compiles the program from files in fsys.
+func New{{.Type}}FS(fsys fs.FS) (*{{.Type}}, error) {
+	s, err := glutils.NewShaderFS(fsys, {{printf "%q" .Vert}}, {{printf "%q" .Frag}}, {{printf "%q" .Geom}})
+	if err != nil {
+		return nil, err
+	}
+	return &{{.Type}}{s}, nil
+}
+{{range .Setters}}
+// {{.Method}} {{.Comment}}
+func (p *{{$type}}) {{.Method}}(v {{.GoType}}) error {
+	return p.{{.Call}}({{printf "%q" .Name}}, v)
+}
+{{end}}
+{{- if .Floats}}
+// AttributesMap describes an interleaved float vertex buffer holding the float
+// vertex inputs of {{.Vert}} in declaration order, and returns it with the
+// stride in floats. Inputs optimized out by the driver are left out of the
+// map, integer inputs are only described by VertexLayout.
+func (p *{{.Type}}) AttributesMap() (glutils.AttributesMap, int32) {
+	am := glutils.NewAttributesMap()
+{{- range .Attributes}}{{if ge .Offset 0}}
+	if loc, ok := p.Attributes[{{printf "%q" .Name}}]; ok {
+		am.Add(loc, {{.Size}}, {{.Offset}})
+	}
+{{- end}}{{end}}
+	return am, {{.Stride}}
+}
+{{end}}
+{{- if .Attributes}}
+// VertexLayout describes an interleaved vertex buffer holding every vertex
+// input of {{.Vert}} in declaration order, 4 byte components, integer inputs
+// included. Inputs optimized out by the driver are left out of the layout.
+func (p *{{.Type}}) VertexLayout() *glutils.VertexLayout {
+	l := &glutils.VertexLayout{Stride: {{.ByteStride}}}
+{{- range .Attributes}}
+	if loc, ok := p.Attributes[{{printf "%q" .Name}}]; ok {
+		l.Attributes = append(l.Attributes, glutils.VertexAttribute{Location: loc, Size: {{.Size}}, Type: gl.{{.Type}}, {{if .Integer}}Integer: true, {{end}}Offset: {{.ByteOffset}}})
+	}
+{{- end}}
+	return l
+}
+{{end -}}
+`))
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Uniform is a plain uniform declaration.
+type Uniform struct {
+	Name      string
+	Type      string
+	ArraySize int
+}
+
+// Input is a vertex shader input. Location is -1 when the declaration has no
+// layout qualifier.
+type Input struct {
+	Name     string
+	Type     string
+	Location int
+}
+
+var (
+	blockComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	lineComment  = regexp.MustCompile(`//[^\n]*`)
+	// uniform highp vec3 lightPos, viewPos; uniform float weights[4];
+	uniformDecl = regexp.MustCompile(`(?m)^\s*(?:layout\s*\([^)]*\)\s*)?uniform\s+(?:(?:lowp|mediump|highp)\s+)?(\w+)\s+([^;{]+);`)
+	// layout (location = 0) in vec3 position; in vec2 uv; attribute vec3 normal;
+	inputDecl = regexp.MustCompile(`(?m)^\s*(?:layout\s*\(([^)]*)\)\s*)?(?:in|attribute)\s+(?:(?:lowp|mediump|highp|flat|smooth|noperspective)\s+)*(\w+)\s+([^;]+);`)
+	location  = regexp.MustCompile(`location\s*=\s*(\d+)`)
+	arrayName = regexp.MustCompile(`^(\w+)\s*(?:\[\s*(\d+)\s*\])?$`)
+)
+
+func stripComments(src string) string {
+	src = blockComment.ReplaceAllStringFunc(src, func(c string) string {
+		// keep the line count so positions stay meaningful
+		return strings.Repeat("\n", strings.Count(c, "\n"))
+	})
+	return lineComment.ReplaceAllString(src, "")
+}
+
+// ParseUniforms returns the plain uniforms declared in a GLSL source.
+// Uniform blocks are ignored.
+func ParseUniforms(src string) []Uniform {
+	var uniforms []Uniform
+	for _, m := range uniformDecl.FindAllStringSubmatch(stripComments(src), -1) {
+		for _, n := range strings.Split(m[2], ",") {
+			nm := arrayName.FindStringSubmatch(strings.TrimSpace(n))
+			if nm == nil {
+				continue
+			}
+			u := Uniform{Name: nm[1], Type: m[1]}
+			if nm[2] != "" {
+				u.ArraySize, _ = strconv.Atoi(nm[2])
+			}
+			uniforms = append(uniforms, u)
+		}
+	}
+	return uniforms
+}
+
+// ParseInputs returns the inputs declared in a vertex shader source.
+func ParseInputs(src string) []Input {
+	var inputs []Input
+	for _, m := range inputDecl.FindAllStringSubmatch(stripComments(src), -1) {
+		loc := -1
+		if l := location.FindStringSubmatch(m[1]); l != nil {
+			loc, _ = strconv.Atoi(l[1])
+		}
+		for i, n := range strings.Split(m[3], ",") {
+			nm := arrayName.FindStringSubmatch(strings.TrimSpace(n))
+			if nm == nil {
+				continue
+			}
+			in := Input{Name: nm[1], Type: m[2], Location: -1}
+			if loc >= 0 {
+				in.Location = loc + i
+			}
+			inputs = append(inputs, in)
+		}
+	}
+	return inputs
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestParseUniforms(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []Uniform
+	}{
+		{"plain", "uniform mat4 model;", []Uniform{{Name: "model", Type: "mat4"}}},
+		{"precision", "uniform highp vec3 color;", []Uniform{{Name: "color", Type: "vec3"}}},
+		{"layout", "layout(location = 3) uniform float time;", []Uniform{{Name: "time", Type: "float"}}},
+		{"array", "uniform float weights[4];", []Uniform{{Name: "weights", Type: "float", ArraySize: 4}}},
+		{
+			"declarators",
+			"uniform vec3 lightPos, viewPos , offsets[ 2 ];",
+			[]Uniform{{Name: "lightPos", Type: "vec3"}, {Name: "viewPos", Type: "vec3"}, {Name: "offsets", Type: "vec3", ArraySize: 2}},
+		},
+		{
+			"comments",
+			"// uniform int a;\n/* uniform int b;\nuniform int c; */\nuniform int d; // uniform int e;",
+			[]Uniform{{Name: "d", Type: "int"}},
+		},
+		{
+			"blocks",
+			"uniform Matrices {\n\tmat4 view;\n};\nlayout (std140) uniform Light { vec3 pos; } light;\nuniform int after;",
+			[]Uniform{{Name: "after", Type: "int"}},
+		},
+		{"indented", "\tuniform sampler2D tex;", []Uniform{{Name: "tex", Type: "sampler2D"}}},
+		{"not at line start", "float x; uniform int i;", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseUniforms(tt.src); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseInputs(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []Input
+	}{
+		{"plain", "in vec3 position;", []Input{{Name: "position", Type: "vec3", Location: -1}}},
+		{"attribute", "attribute vec2 uv;", []Input{{Name: "uv", Type: "vec2", Location: -1}}},
+		{"location", "layout (location = 2) in vec3 normal;", []Input{{Name: "normal", Type: "vec3", Location: 2}}},
+		{"compact location", "layout(location=7) in ivec4 ids;", []Input{{Name: "ids", Type: "ivec4", Location: 7}}},
+		{"interpolation", "in flat int id;", []Input{{Name: "id", Type: "int", Location: -1}}},
+		{
+			"declarators",
+			"layout (location = 2) in vec3 a, b;\nin float c, d;",
+			[]Input{
+				{Name: "a", Type: "vec3", Location: 2},
+				{Name: "b", Type: "vec3", Location: 3},
+				{Name: "c", Type: "float", Location: -1},
+				{Name: "d", Type: "float", Location: -1},
+			},
+		},
+		{
+			"comments",
+			"// in vec3 a;\n/*\nlayout (location = 0) in vec3 b;\n*/\nin vec3 c; // in vec3 d;",
+			[]Input{{Name: "c", Type: "vec3", Location: -1}},
+		},
+		{"outputs", "out vec2 uv;\ninout float x;", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseInputs(tt.src); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateGolden(t *testing.T) {
+	got, err := generate(options{
+		Type:    "Basic",
+		Package: "shaders",
+		Vert:    filepath.Join("testdata", "basic.vs"),
+		Frag:    filepath.Join("testdata", "basic.frag"),
+		Out:     filepath.Join("testdata", "basic_gen.go"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "basic.golden")
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("generated bindings differ from %s, run go test -update if the change is intended:\n%s", golden, got)
+	}
+}
@@ -0,0 +1,15 @@
+#version 410 core
+#include "light.glsl"
+
+in vec2 uv;
+out vec4 color;
+
+// uniform float commented;
+uniform sampler2D texture_diffuse1;
+uniform Material {
+	vec4 diffuse;
+};
+
+void main() {
+	color = texture(texture_diffuse1, uv);
+}
@@ -0,0 +1,17 @@
+#version 410 core
+#include "light.glsl"
+
+layout (location = 0) in vec3 position;
+layout (location = 1) in vec3 normal;
+layout (location = 2) in vec2 texCoord;
+layout (location = 3) in ivec4 boneIDs;
+
+uniform mat4 model;
+uniform mat4 view, projection;
+
+out vec2 uv;
+
+void main() {
+	uv = texCoord;
+	gl_Position = projection * view * model * vec4(position, 1.0);
+}
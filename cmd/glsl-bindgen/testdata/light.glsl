@@ -0,0 +1,2 @@
+uniform vec3 lightPos, viewPos;
+uniform float weights[4];
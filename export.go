@@ -0,0 +1,589 @@
+package glutils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// objTextureMaps maps texture types to MTL statements, the reverse of
+// OBJMaterial.Textures.
+var objTextureMaps = []struct{ textureType, statement string }{
+	{"texture_diffuse", "map_Kd"},
+	{"texture_specular", "map_Ks"},
+	{"texture_normal", "map_Bump"},
+	{"texture_height", "map_Ka"},
+}
+
+// WriteOBJ writes meshes as the objects of an OBJ file, each using the
+// material of the same index written by WriteMTL to mtlName, when not empty.
+// Tangents are not part of the format and are left out.
+func WriteOBJ(w io.Writer, meshes []Mesh, mtlName string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# exported by glutils")
+	if mtlName != "" {
+		fmt.Fprintf(bw, "mtllib %s\n", mtlName)
+	}
+	offset := 1
+	for i, m := range meshes {
+		fmt.Fprintf(bw, "o mesh%d\n", i)
+		for _, v := range m.Vertices {
+			fmt.Fprintf(bw, "v %g %g %g\n", v.Position[0], v.Position[1], v.Position[2])
+		}
+		for _, v := range m.Vertices {
+			// the loaders flip texture coordinates vertically
+			fmt.Fprintf(bw, "vt %g %g\n", v.TexCoords[0], 1-v.TexCoords[1])
+		}
+		for _, v := range m.Vertices {
+			fmt.Fprintf(bw, "vn %g %g %g\n", v.Normal[0], v.Normal[1], v.Normal[2])
+		}
+		if mtlName != "" {
+			fmt.Fprintf(bw, "usemtl material%d\n", i)
+		}
+		if m.Points {
+			for _, k := range m.Indices {
+				fmt.Fprintf(bw, "p %d\n", int(k)+offset)
+			}
+		}
+		for k := 0; !m.Points && k+2 < len(m.Indices); k += 3 {
+			a, b, c := int(m.Indices[k])+offset, int(m.Indices[k+1])+offset, int(m.Indices[k+2])+offset
+			fmt.Fprintf(bw, "f %d/%d/%d %d/%d/%d %d/%d/%d\n", a, a, a, b, b, b, c, c, c)
+		}
+		offset += len(m.Vertices)
+	}
+	return bw.Flush()
+}
+
+// WriteMTL writes the Material of each mesh, named material0, material1...,
+// referencing the texture maps of the mesh by their Path.
+func WriteMTL(w io.Writer, meshes []Mesh) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# exported by glutils")
+	for i, m := range meshes {
+		mt := m.Material
+		if mt == nil {
+			mt = &DefaultMaterial
+		}
+		fmt.Fprintf(bw, "\nnewmtl material%d\n", i)
+		for _, c := range []struct {
+			statement string
+			color     mgl32.Vec3
+		}{{"Ka", mt.Ambient}, {"Kd", mt.Diffuse}, {"Ks", mt.Specular}, {"Ke", mt.Emissive}} {
+			fmt.Fprintf(bw, "%s %g %g %g\n", c.statement, c.color[0], c.color[1], c.color[2])
+		}
+		fmt.Fprintf(bw, "Ns %g\nd %g\n", mt.Shininess, mt.Opacity)
+		for _, tm := range objTextureMaps {
+			for _, t := range m.Textures {
+				if t.TextureType == tm.textureType {
+					fmt.Fprintf(bw, "%s %s\n", tm.statement, t.Path)
+					break
+				}
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteSTL writes the triangles of meshes as a binary STL file, point clouds
+// are left out.
+func WriteSTL(w io.Writer, meshes []Mesh) error {
+	bw := bufio.NewWriter(w)
+	var header [80]byte
+	copy(header[:], "exported by glutils")
+	bw.Write(header[:])
+
+	count := 0
+	for _, m := range meshes {
+		if !m.Points {
+			count += len(m.Indices) / 3
+		}
+	}
+	binary.Write(bw, binary.LittleEndian, uint32(count))
+
+	var tri [50]byte
+	put := func(b []byte, v mgl32.Vec3) {
+		for i := range v {
+			binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(v[i]))
+		}
+	}
+	for _, m := range meshes {
+		for k := 0; !m.Points && k+2 < len(m.Indices); k += 3 {
+			a := m.Vertices[m.Indices[k]].Position
+			b := m.Vertices[m.Indices[k+1]].Position
+			c := m.Vertices[m.Indices[k+2]].Position
+			n := b.Sub(a).Cross(c.Sub(a))
+			if n.Len() > 0 {
+				n = n.Normalize()
+			}
+			put(tri[0:], n)
+			put(tri[12:], a)
+			put(tri[24:], b)
+			put(tri[36:], c)
+			if _, err := bw.Write(tri[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// glTF structures written by WriteGLB.
+type (
+	gltfOutTextureInfo struct {
+		Index int `json:"index"`
+	}
+	gltfOutMaterial struct {
+		Name                 string              `json:"name,omitempty"`
+		PbrMetallicRoughness gltfOutPBR          `json:"pbrMetallicRoughness"`
+		NormalTexture        *gltfOutTextureInfo `json:"normalTexture,omitempty"`
+		OcclusionTexture     *gltfOutTextureInfo `json:"occlusionTexture,omitempty"`
+		EmissiveTexture      *gltfOutTextureInfo `json:"emissiveTexture,omitempty"`
+		EmissiveFactor       []float32           `json:"emissiveFactor,omitempty"`
+		AlphaMode            string              `json:"alphaMode,omitempty"`
+		DoubleSided          bool                `json:"doubleSided,omitempty"`
+	}
+	gltfOutPBR struct {
+		BaseColorFactor          []float32           `json:"baseColorFactor"`
+		BaseColorTexture         *gltfOutTextureInfo `json:"baseColorTexture,omitempty"`
+		MetallicRoughnessTexture *gltfOutTextureInfo `json:"metallicRoughnessTexture,omitempty"`
+		MetallicFactor           float32             `json:"metallicFactor"`
+		RoughnessFactor          float32             `json:"roughnessFactor"`
+	}
+	gltfOutAccessor struct {
+		BufferView    int       `json:"bufferView"`
+		ComponentType int       `json:"componentType"`
+		Count         int       `json:"count"`
+		Type          string    `json:"type"`
+		Min           []float32 `json:"min,omitempty"`
+		Max           []float32 `json:"max,omitempty"`
+	}
+	gltfOutBufferView struct {
+		Buffer     int `json:"buffer"`
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+		Target     int `json:"target,omitempty"`
+	}
+	gltfOutImage struct {
+		URI        string `json:"uri,omitempty"`
+		BufferView *int   `json:"bufferView,omitempty"`
+		MimeType   string `json:"mimeType,omitempty"`
+	}
+	gltfOutPrimitive struct {
+		Attributes map[string]int `json:"attributes"`
+		Indices    int            `json:"indices"`
+		Material   int            `json:"material"`
+		Mode       *int           `json:"mode,omitempty"`
+	}
+	gltfOutMesh struct {
+		Primitives []gltfOutPrimitive `json:"primitives"`
+	}
+	gltfOutNode struct {
+		Name     string    `json:"name,omitempty"`
+		Mesh     *int      `json:"mesh,omitempty"`
+		Children []int     `json:"children,omitempty"`
+		Matrix   []float32 `json:"matrix,omitempty"`
+	}
+	gltfOutDoc struct {
+		Asset struct {
+			Version   string `json:"version"`
+			Generator string `json:"generator"`
+		} `json:"asset"`
+		Scene  int `json:"scene"`
+		Scenes []struct {
+			Nodes []int `json:"nodes"`
+		} `json:"scenes"`
+		Nodes       []gltfOutNode       `json:"nodes"`
+		Meshes      []gltfOutMesh       `json:"meshes"`
+		Materials   []gltfOutMaterial   `json:"materials,omitempty"`
+		Textures    []map[string]int    `json:"textures,omitempty"`
+		Images      []gltfOutImage      `json:"images,omitempty"`
+		Accessors   []gltfOutAccessor   `json:"accessors"`
+		BufferViews []gltfOutBufferView `json:"bufferViews"`
+		Buffers     []map[string]int    `json:"buffers"`
+	}
+)
+
+// glbWriter accumulates the binary chunk and the JSON of a GLB file.
+type glbWriter struct {
+	doc    gltfOutDoc
+	bin    bytes.Buffer
+	images map[string]int
+}
+
+func (g *glbWriter) view(data []byte, target int) int {
+	for g.bin.Len()%4 != 0 {
+		g.bin.WriteByte(0)
+	}
+	g.doc.BufferViews = append(g.doc.BufferViews, gltfOutBufferView{
+		ByteOffset: g.bin.Len(),
+		ByteLength: len(data),
+		Target:     target,
+	})
+	g.bin.Write(data)
+	return len(g.doc.BufferViews) - 1
+}
+
+func (g *glbWriter) floats(values []float32, comps int, typ string, bounds bool) int {
+	data := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+	a := gltfOutAccessor{
+		BufferView:    g.view(data, 34962),
+		ComponentType: gltfFloat,
+		Count:         len(values) / comps,
+		Type:          typ,
+	}
+	if bounds && len(values) > 0 {
+		a.Min = append([]float32(nil), values[:comps]...)
+		a.Max = append([]float32(nil), values[:comps]...)
+		for i, v := range values {
+			c := i % comps
+			a.Min[c] = float32(math.Min(float64(a.Min[c]), float64(v)))
+			a.Max[c] = float32(math.Max(float64(a.Max[c]), float64(v)))
+		}
+	}
+	g.doc.Accessors = append(g.doc.Accessors, a)
+	return len(g.doc.Accessors) - 1
+}
+
+func (g *glbWriter) indices(indices []uint32) int {
+	data := make([]byte, len(indices)*4)
+	for i, v := range indices {
+		binary.LittleEndian.PutUint32(data[i*4:], v)
+	}
+	g.doc.Accessors = append(g.doc.Accessors, gltfOutAccessor{
+		BufferView:    g.view(data, 34963),
+		ComponentType: gltfUnsignedInt,
+		Count:         len(indices),
+		Type:          "SCALAR",
+	})
+	return len(g.doc.Accessors) - 1
+}
+
+// texture returns the texture info of t, embedding its data or referencing
+// its Path as a URI.
+func (g *glbWriter) texture(t Texture) *gltfOutTextureInfo {
+	key := t.Path
+	img, ok := g.images[key]
+	if !ok {
+		var image gltfOutImage
+		if t.Data != nil {
+			v := g.view(t.Data, 0)
+			image.BufferView = &v
+			image.MimeType = http.DetectContentType(t.Data)
+		} else {
+			image.URI = (&url.URL{Path: filepath.ToSlash(t.Path)}).String()
+		}
+		g.doc.Images = append(g.doc.Images, image)
+		g.doc.Textures = append(g.doc.Textures, map[string]int{"source": len(g.doc.Images) - 1})
+		img = len(g.doc.Textures) - 1
+		g.images[key] = img
+	}
+	return &gltfOutTextureInfo{Index: img}
+}
+
+// WriteGLB writes meshes as a binary glTF 2.0 file, one node per mesh, with
+// normals, texture coordinates, tangents and a material made of the mesh
+// Material and texture maps. Embedded textures are written in the file, the
+// others are referenced by their Path.
+func WriteGLB(w io.Writer, meshes []Mesh) error {
+	return WriteGLBNodes(w, meshes, nil)
+}
+
+// WriteGLBNodes is WriteGLB with the meshes placed by the node tree of root,
+// written with the node names and local transforms. The meshes of a node are
+// the primitives of its glTF mesh. A nil root writes one node per mesh.
+func WriteGLBNodes(w io.Writer, meshes []Mesh, root *Node) error {
+	g := &glbWriter{images: make(map[string]int)}
+	g.doc.Asset.Version = "2.0"
+	g.doc.Asset.Generator = "glutils"
+	g.doc.Scenes = make([]struct {
+		Nodes []int `json:"nodes"`
+	}, 1)
+
+	var prims []gltfOutPrimitive
+	for i, m := range meshes {
+		n := len(m.Vertices)
+		positions := make([]float32, 0, n*3)
+		normals := make([]float32, 0, n*3)
+		texCoords := make([]float32, 0, n*2)
+		tangents := make([]float32, 0, n*4)
+		hasTangents := false
+		for _, v := range m.Vertices {
+			positions = append(positions, v.Position[:]...)
+			normals = append(normals, v.Normal[:]...)
+			texCoords = append(texCoords, v.TexCoords[:]...)
+			// the handedness is the side of the bitangent
+			w := float32(1)
+			if v.Normal.Cross(v.Tangent).Dot(v.Bitangent) < 0 {
+				w = -1
+			}
+			tangents = append(tangents, v.Tangent[0], v.Tangent[1], v.Tangent[2], w)
+			hasTangents = hasTangents || v.Tangent.Len() > 0
+		}
+		attributes := map[string]int{
+			"POSITION":   g.floats(positions, 3, "VEC3", true),
+			"NORMAL":     g.floats(normals, 3, "VEC3", false),
+			"TEXCOORD_0": g.floats(texCoords, 2, "VEC2", false),
+		}
+		if hasTangents {
+			attributes["TANGENT"] = g.floats(tangents, 4, "VEC4", false)
+		}
+
+		mt := m.Material
+		if mt == nil {
+			mt = &DefaultMaterial
+		}
+		mat := gltfOutMaterial{
+			Name: mt.Name,
+			PbrMetallicRoughness: gltfOutPBR{
+				BaseColorFactor: []float32{mt.Diffuse[0], mt.Diffuse[1], mt.Diffuse[2], mt.Opacity},
+				MetallicFactor:  0,
+				RoughnessFactor: roughness(mt.Shininess),
+			},
+			DoubleSided: mt.TwoSided,
+		}
+		if mt.Emissive != (mgl32.Vec3{}) {
+			mat.EmissiveFactor = mt.Emissive[:]
+		}
+		if mt.Opacity < 1 {
+			mat.AlphaMode = "BLEND"
+		}
+		for _, t := range m.Textures {
+			switch t.TextureType {
+			case "texture_diffuse":
+				if mat.PbrMetallicRoughness.BaseColorTexture == nil {
+					mat.PbrMetallicRoughness.BaseColorTexture = g.texture(t)
+				}
+			case "texture_metallic_roughness":
+				if mat.PbrMetallicRoughness.MetallicRoughnessTexture == nil {
+					mat.PbrMetallicRoughness.MetallicRoughnessTexture = g.texture(t)
+					mat.PbrMetallicRoughness.MetallicFactor = 1
+				}
+			case "texture_normal":
+				if mat.NormalTexture == nil {
+					mat.NormalTexture = g.texture(t)
+				}
+			case "texture_occlusion":
+				if mat.OcclusionTexture == nil {
+					mat.OcclusionTexture = g.texture(t)
+				}
+			case "texture_emissive":
+				if mat.EmissiveTexture == nil {
+					mat.EmissiveTexture = g.texture(t)
+				}
+			}
+		}
+		g.doc.Materials = append(g.doc.Materials, mat)
+
+		prim := gltfOutPrimitive{
+			Attributes: attributes,
+			Indices:    g.indices(m.Indices),
+			Material:   i,
+		}
+		if m.Points {
+			points := 0
+			prim.Mode = &points
+		}
+		prims = append(prims, prim)
+	}
+	if root == nil {
+		for i, prim := range prims {
+			mesh := i
+			g.doc.Meshes = append(g.doc.Meshes, gltfOutMesh{Primitives: []gltfOutPrimitive{prim}})
+			g.doc.Nodes = append(g.doc.Nodes, gltfOutNode{Name: fmt.Sprintf("mesh%d", i), Mesh: &mesh})
+			g.doc.Scenes[0].Nodes = append(g.doc.Scenes[0].Nodes, i)
+		}
+	} else {
+		n, err := g.node(root, prims, make(map[string]int))
+		if err != nil {
+			return err
+		}
+		g.doc.Scenes[0].Nodes = []int{n}
+	}
+	for g.bin.Len()%4 != 0 {
+		g.bin.WriteByte(0)
+	}
+	g.doc.Buffers = []map[string]int{{"byteLength": g.bin.Len()}}
+
+	js, err := json.Marshal(g.doc)
+	if err != nil {
+		return err
+	}
+	for len(js)%4 != 0 {
+		js = append(js, ' ')
+	}
+	length := 12 + 8 + len(js) + 8 + g.bin.Len()
+	bw := bufio.NewWriter(w)
+	binary.Write(bw, binary.LittleEndian, []uint32{0x46546C67, 2, uint32(length)})
+	binary.Write(bw, binary.LittleEndian, []uint32{uint32(len(js)), 0x4E4F534A})
+	bw.Write(js)
+	binary.Write(bw, binary.LittleEndian, []uint32{uint32(g.bin.Len()), 0x004E4942})
+	bw.Write(g.bin.Bytes())
+	return bw.Flush()
+}
+
+// node appends n and its descendants to the nodes of the document and returns
+// the index of n. Nodes drawing the same meshes share a glTF mesh, found in
+// meshes by the list of their indices.
+func (g *glbWriter) node(n *Node, prims []gltfOutPrimitive, meshes map[string]int) (int, error) {
+	out := gltfOutNode{Name: n.Name}
+	if n.Transform != mgl32.Ident4() {
+		out.Matrix = append([]float32(nil), n.Transform[:]...)
+	}
+	if len(n.Meshes) > 0 {
+		key := fmt.Sprint(n.Meshes)
+		mesh, ok := meshes[key]
+		if !ok {
+			var m gltfOutMesh
+			for _, i := range n.Meshes {
+				if i < 0 || i >= len(prims) {
+					return 0, fmt.Errorf("node %q: mesh %d out of range", n.Name, i)
+				}
+				m.Primitives = append(m.Primitives, prims[i])
+			}
+			mesh = len(g.doc.Meshes)
+			g.doc.Meshes = append(g.doc.Meshes, m)
+			meshes[key] = mesh
+		}
+		out.Mesh = &mesh
+	}
+	index := len(g.doc.Nodes)
+	g.doc.Nodes = append(g.doc.Nodes, out)
+	for _, c := range n.Children {
+		child, err := g.node(c, prims, meshes)
+		if err != nil {
+			return 0, err
+		}
+		g.doc.Nodes[index].Children = append(g.doc.Nodes[index].Children, child)
+	}
+	return index, nil
+}
+
+// worldMeshes returns a copy of the meshes of every node of root transformed
+// by the node world matrix, for formats without a hierarchy. Meshes drawn by
+// several nodes are copied for each.
+func worldMeshes(meshes []Mesh, root *Node) ([]Mesh, error) {
+	var (
+		out []Mesh
+		err error
+	)
+	root.Walk(mgl32.Ident4(), func(n *Node, world mgl32.Mat4) {
+		for _, i := range n.Meshes {
+			if i < 0 || i >= len(meshes) {
+				if err == nil {
+					err = fmt.Errorf("node %q: mesh %d out of range", n.Name, i)
+				}
+				continue
+			}
+			out = append(out, transformMesh(meshes[i], world))
+		}
+	})
+	return out, err
+}
+
+// transformMesh returns a copy of m with its vertices transformed by world.
+// The winding of triangles is reversed when world mirrors them.
+func transformMesh(m Mesh, world mgl32.Mat4) Mesh {
+	if world == mgl32.Ident4() {
+		return m
+	}
+	linear := world.Mat3()
+	normalMatrix := linear.Inv().Transpose()
+	unit := func(v mgl32.Vec3) mgl32.Vec3 {
+		if v.Len() > 0 {
+			return v.Normalize()
+		}
+		return v
+	}
+	vertices := make([]Vertex, len(m.Vertices))
+	for i, v := range m.Vertices {
+		v.Position = mgl32.TransformCoordinate(v.Position, world)
+		v.Normal = unit(normalMatrix.Mul3x1(v.Normal))
+		v.Tangent = unit(linear.Mul3x1(v.Tangent))
+		v.Bitangent = unit(linear.Mul3x1(v.Bitangent))
+		vertices[i] = v
+	}
+	m.Vertices = vertices
+	if linear.Det() < 0 && !m.Points {
+		indices := append([]uint32(nil), m.Indices...)
+		for k := 0; k+2 < len(indices); k += 3 {
+			indices[k+1], indices[k+2] = indices[k+2], indices[k+1]
+		}
+		m.Indices = indices
+	}
+	return m
+}
+
+// exportMeshes returns the meshes with texture paths relative to dir.
+func (m *Model) exportMeshes(dir string) []Mesh {
+	meshes := make([]Mesh, len(m.Meshes))
+	for i, mesh := range m.Meshes {
+		mesh.Textures = append([]Texture(nil), mesh.Textures...)
+		for j := range mesh.Textures {
+			t := &mesh.Textures[j]
+			if t.Data != nil {
+				continue
+			}
+			if rel, err := filepath.Rel(dir, t.Path); err == nil {
+				t.Path = rel
+			}
+		}
+		meshes[i] = mesh
+	}
+	return meshes
+}
+
+func createAndWrite(file string, write func(io.Writer) error) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ExportOBJ writes the model to an OBJ file and its materials to the MTL
+// file of the same name. The meshes are placed by their node world matrix.
+func (m *Model) ExportOBJ(file string) error {
+	meshes, err := worldMeshes(m.exportMeshes(filepath.Dir(file)), m.root())
+	if err != nil {
+		return err
+	}
+	mtl := strings.TrimSuffix(file, filepath.Ext(file)) + ".mtl"
+	if err := createAndWrite(mtl, func(w io.Writer) error { return WriteMTL(w, meshes) }); err != nil {
+		return err
+	}
+	return createAndWrite(file, func(w io.Writer) error { return WriteOBJ(w, meshes, filepath.Base(mtl)) })
+}
+
+// ExportSTL writes the model to a binary STL file. The meshes are placed by
+// their node world matrix.
+func (m *Model) ExportSTL(file string) error {
+	meshes, err := worldMeshes(m.Meshes, m.root())
+	if err != nil {
+		return err
+	}
+	return createAndWrite(file, func(w io.Writer) error { return WriteSTL(w, meshes) })
+}
+
+// ExportGLB writes the model and its node tree to a binary glTF file.
+func (m *Model) ExportGLB(file string) error {
+	meshes := m.exportMeshes(filepath.Dir(file))
+	return createAndWrite(file, func(w io.Writer) error { return WriteGLBNodes(w, meshes, m.root()) })
+}
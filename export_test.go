@@ -0,0 +1,220 @@
+package glutils
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func exportTestScene() ([]Mesh, *Node) {
+	triangle := NewMesh([]Vertex{
+		{Position: mgl32.Vec3{0, 0, 0}, Normal: mgl32.Vec3{0, 0, 1}},
+		{Position: mgl32.Vec3{1, 0, 0}, Normal: mgl32.Vec3{0, 0, 1}},
+		{Position: mgl32.Vec3{0, 1, 0}, Normal: mgl32.Vec3{0, 0, 1}},
+	}, []uint32{0, 1, 2}, nil)
+	root := NewNode("root", mgl32.Translate3D(0, 0, 5))
+	left := NewNode("left", mgl32.Translate3D(-2, 0, 0))
+	left.Meshes = []int{0}
+	mirrored := NewNode("mirrored", mgl32.Scale3D(-1, 1, 1))
+	mirrored.Meshes = []int{0}
+	root.AddChild(left)
+	root.AddChild(mirrored)
+	return []Mesh{triangle}, root
+}
+
+func TestWorldMeshes(t *testing.T) {
+	meshes, root := exportTestScene()
+	world, err := worldMeshes(meshes, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(world) != 2 {
+		t.Fatalf("got %d meshes, want one per node", len(world))
+	}
+	if p := world[0].Vertices[1].Position; p != (mgl32.Vec3{-1, 0, 5}) {
+		t.Errorf("left vertex at %v, want [-1 0 5]", p)
+	}
+	if p := world[1].Vertices[1].Position; p != (mgl32.Vec3{-1, 0, 5}) {
+		t.Errorf("mirrored vertex at %v, want [-1 0 5]", p)
+	}
+	// mirroring reverses the winding, the face still points to +z
+	m := world[1]
+	a, b, c := m.Vertices[m.Indices[0]].Position, m.Vertices[m.Indices[1]].Position, m.Vertices[m.Indices[2]].Position
+	if n := b.Sub(a).Cross(c.Sub(a)); n[2] <= 0 {
+		t.Errorf("mirrored triangle faces %v", n)
+	}
+	if meshes[0].Vertices[1].Position != (mgl32.Vec3{1, 0, 0}) || meshes[0].Indices[1] != 1 {
+		t.Error("the source mesh was modified")
+	}
+	if _, err := worldMeshes(meshes, &Node{Name: "bad", Meshes: []int{1}, Transform: mgl32.Ident4()}); err == nil {
+		t.Error("no error for a mesh index out of range")
+	}
+}
+
+func TestWriteGLBNodes(t *testing.T) {
+	meshes, root := exportTestScene()
+	var b bytes.Buffer
+	if err := WriteGLBNodes(&b, meshes, root); err != nil {
+		t.Fatal(err)
+	}
+	g, err := ParseGLTF(b.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Meshes) != 1 {
+		t.Errorf("got %d meshes, want the shared mesh once", len(g.Meshes))
+	}
+	for _, c := range []struct {
+		name string
+		want mgl32.Mat4
+	}{
+		{"root", mgl32.Translate3D(0, 0, 5)},
+		{"left", mgl32.Translate3D(-2, 0, 5)},
+		{"mirrored", mgl32.Translate3D(0, 0, 5).Mul4(mgl32.Scale3D(-1, 1, 1))},
+	} {
+		n := g.Root.Find(c.name)
+		if n == nil {
+			t.Errorf("no node %q", c.name)
+			continue
+		}
+		if got := n.WorldMatrix(); !got.ApproxEqual(c.want) {
+			t.Errorf("node %q world matrix %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// roundTripMeshes is a textured quad with tangents of both handednesses.
+func roundTripMeshes() []Mesh {
+	vertex := func(x, y float32, bitangent mgl32.Vec3) Vertex {
+		return Vertex{
+			Position:  mgl32.Vec3{x, y, 0.5},
+			Normal:    mgl32.Vec3{0, 0, 1},
+			TexCoords: mgl32.Vec2{x, 0.25 * y},
+			Tangent:   mgl32.Vec3{1, 0, 0},
+			Bitangent: bitangent,
+		}
+	}
+	up, down := mgl32.Vec3{0, 1, 0}, mgl32.Vec3{0, -1, 0}
+	quad := NewMesh([]Vertex{
+		vertex(0, 0, up), vertex(1, 0, up), vertex(1, 1, down), vertex(0, 1, down),
+	}, []uint32{0, 1, 2, 0, 2, 3}, []Texture{
+		{TextureType: "texture_diffuse", Path: "maps/diffuse map.png"},
+		{TextureType: "texture_normal", Path: "maps/normal.png"},
+	})
+	quad.Material = &Material{
+		Name:      "painted",
+		Ambient:   mgl32.Vec3{0.1, 0.1, 0.1},
+		Diffuse:   mgl32.Vec3{0.8, 0.2, 0.2},
+		Specular:  mgl32.Vec3{0.5, 0.5, 0.5},
+		Shininess: 32,
+		Opacity:   1,
+	}
+	return []Mesh{quad}
+}
+
+// checkCorners compares the triangle corners of got and want, the vertices of
+// a format may be ordered or welded differently.
+func checkCorners(t *testing.T, format string, got, want Mesh, normals, texCoords, tangents bool) {
+	t.Helper()
+	if len(got.Indices) != len(want.Indices) {
+		t.Fatalf("%s: got %d indices, want %d", format, len(got.Indices), len(want.Indices))
+	}
+	for k := range want.Indices {
+		g, w := got.Vertices[got.Indices[k]], want.Vertices[want.Indices[k]]
+		if !g.Position.ApproxEqual(w.Position) {
+			t.Errorf("%s: corner %d at %v, want %v", format, k, g.Position, w.Position)
+		}
+		if normals && !g.Normal.ApproxEqual(w.Normal) {
+			t.Errorf("%s: corner %d normal %v, want %v", format, k, g.Normal, w.Normal)
+		}
+		if texCoords && !g.TexCoords.ApproxEqual(w.TexCoords) {
+			t.Errorf("%s: corner %d texture coordinates %v, want %v", format, k, g.TexCoords, w.TexCoords)
+		}
+		if tangents && (!g.Tangent.ApproxEqual(w.Tangent) || !g.Bitangent.ApproxEqual(w.Bitangent)) {
+			t.Errorf("%s: corner %d tangent %v and bitangent %v, want %v and %v", format, k, g.Tangent, g.Bitangent, w.Tangent, w.Bitangent)
+		}
+	}
+}
+
+func checkTexturePaths(t *testing.T, format string, got, want []Texture) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got textures %+v, want %+v", format, got, want)
+	}
+	for i := range want {
+		if got[i].TextureType != want[i].TextureType || got[i].Path != want[i].Path {
+			t.Errorf("%s: texture %d is %s %q, want %s %q", format, i, got[i].TextureType, got[i].Path, want[i].TextureType, want[i].Path)
+		}
+	}
+}
+
+func TestOBJRoundTrip(t *testing.T) {
+	meshes := roundTripMeshes()
+	var obj, mtl bytes.Buffer
+	if err := WriteOBJ(&obj, meshes, "scene.mtl"); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMTL(&mtl, meshes); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseOBJ(&obj, func(name string) (io.ReadCloser, error) {
+		if name != "scene.mtl" {
+			t.Errorf("material library %q, want scene.mtl", name)
+		}
+		return ioutil.NopCloser(&mtl), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d meshes, want 1", len(got))
+	}
+	// OBJ has no tangents
+	checkCorners(t, "obj", got[0], meshes[0], true, true, false)
+	checkTexturePaths(t, "obj", got[0].Textures, meshes[0].Textures)
+	want := *meshes[0].Material
+	if mt := got[0].Material; mt == nil || mt.Ambient != want.Ambient || mt.Diffuse != want.Diffuse ||
+		mt.Specular != want.Specular || mt.Shininess != want.Shininess || mt.Opacity != want.Opacity {
+		t.Errorf("material %+v, want %+v", mt, want)
+	}
+}
+
+func TestSTLRoundTrip(t *testing.T) {
+	meshes := roundTripMeshes()
+	var b bytes.Buffer
+	if err := WriteSTL(&b, meshes); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseSTL(&b, DefaultSTLSmoothingAngle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// STL keeps positions, normals are rebuilt from the faces
+	checkCorners(t, "stl", got, meshes[0], true, false, false)
+	if len(got.Vertices) != 4 {
+		t.Errorf("got %d vertices, want the 4 corners welded", len(got.Vertices))
+	}
+}
+
+func TestGLBRoundTrip(t *testing.T) {
+	meshes := roundTripMeshes()
+	var b bytes.Buffer
+	if err := WriteGLB(&b, meshes); err != nil {
+		t.Fatal(err)
+	}
+	g, err := ParseGLTF(b.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Meshes) != 1 {
+		t.Fatalf("got %d meshes, want 1", len(g.Meshes))
+	}
+	checkCorners(t, "glb", g.Meshes[0], meshes[0], true, true, true)
+	checkTexturePaths(t, "glb", g.Meshes[0].Textures, meshes[0].Textures)
+	if mt := g.Meshes[0].Material; mt == nil || mt.Name != "painted" || !mt.Diffuse.ApproxEqual(meshes[0].Material.Diffuse) {
+		t.Errorf("material %+v", mt)
+	}
+}
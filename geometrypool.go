@@ -0,0 +1,214 @@
+package glutils
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// GeometryRange is the place of a mesh in a GeometryPool. Its indices are
+// relative to its first vertex.
+type GeometryRange struct {
+	BaseVertex  int32
+	FirstIndex  int32
+	IndexCount  int32
+	VertexCount int32
+}
+
+// GeometryPool packs the vertices and indices of many meshes sharing a vertex
+// layout in a single VAO, vertex buffer and uint32 index buffer, so that they
+// can be drawn without switching buffers, or many at once with a
+// GeometryBatch. The buffers grow as meshes are added.
+//
+// Primitive is the type of primitive drawn, gl.TRIANGLES when left unset. Use
+// SetPrimitive to draw gl.POINTS, which is 0.
+type GeometryPool struct {
+	Layout        *VertexLayout
+	Primitive     uint32
+	Vao, Vbo, Ebo uint32
+
+	vertexCap, indexCap int
+	vertices, indices   int
+	primitiveSet        bool
+}
+
+// NewGeometryPool allocates a pool with room for the given number of vertices
+// and indices.
+func NewGeometryPool(layout *VertexLayout, vertices, indices int) *GeometryPool {
+	p := &GeometryPool{Layout: layout}
+	gl.GenVertexArrays(1, &p.Vao)
+	p.grow(vertices, indices)
+	return p
+}
+
+// grow moves the data to buffers with the given capacities.
+func (p *GeometryPool) grow(vertexCap, indexCap int) {
+	stride := p.Layout.Stride
+	p.Vbo = resizeBuffer(p.Vbo, p.vertices*stride, vertexCap*stride)
+	p.Ebo = resizeBuffer(p.Ebo, p.indices*4, indexCap*4)
+	p.vertexCap, p.indexCap = vertexCap, indexCap
+
+	gl.BindVertexArray(p.Vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, p.Vbo)
+	p.Layout.apply()
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, p.Ebo)
+	gl.BindVertexArray(0)
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+}
+
+// resizeBuffer returns a new buffer of size bytes holding the first used bytes
+// of buffer, which is deleted.
+func resizeBuffer(buffer uint32, used, size int) uint32 {
+	var b uint32
+	gl.GenBuffers(1, &b)
+	gl.BindBuffer(gl.COPY_WRITE_BUFFER, b)
+	gl.BufferData(gl.COPY_WRITE_BUFFER, size, nil, gl.STATIC_DRAW)
+	if buffer != 0 {
+		if used > 0 {
+			gl.BindBuffer(gl.COPY_READ_BUFFER, buffer)
+			gl.CopyBufferSubData(gl.COPY_READ_BUFFER, gl.COPY_WRITE_BUFFER, 0, 0, used)
+			gl.BindBuffer(gl.COPY_READ_BUFFER, 0)
+		}
+		gl.DeleteBuffers(1, &buffer)
+	}
+	gl.BindBuffer(gl.COPY_WRITE_BUFFER, 0)
+	return b
+}
+
+// Add appends vertices laid out as Layout and their indices.
+func (p *GeometryPool) Add(vertices []byte, indices []uint32) (GeometryRange, error) {
+	stride := p.Layout.Stride
+	if stride == 0 || len(vertices)%stride != 0 {
+		return GeometryRange{}, fmt.Errorf("geometry pool: %d bytes of vertices is not a multiple of the stride %d", len(vertices), stride)
+	}
+	var ptr unsafe.Pointer
+	if len(vertices) > 0 {
+		ptr = gl.Ptr(vertices)
+	}
+	return p.add(len(vertices)/stride, ptr, indices)
+}
+
+// AddFloats appends float vertices laid out as Layout and their indices.
+func (p *GeometryPool) AddFloats(vertices []float32, indices []uint32) (GeometryRange, error) {
+	stride := p.Layout.Stride
+	size := len(vertices) * GL_FLOAT32_SIZE
+	if stride == 0 || size%stride != 0 {
+		return GeometryRange{}, fmt.Errorf("geometry pool: %d bytes of vertices is not a multiple of the stride %d", size, stride)
+	}
+	var ptr unsafe.Pointer
+	if len(vertices) > 0 {
+		ptr = gl.Ptr(vertices)
+	}
+	return p.add(size/stride, ptr, indices)
+}
+
+func (p *GeometryPool) add(count int, vertices unsafe.Pointer, indices []uint32) (GeometryRange, error) {
+	for _, i := range indices {
+		if int(i) >= count {
+			return GeometryRange{}, fmt.Errorf("geometry pool: index %d out of %d vertices", i, count)
+		}
+	}
+	if p.vertices+count > p.vertexCap || p.indices+len(indices) > p.indexCap {
+		vertexCap, indexCap := p.vertexCap, p.indexCap
+		for vertexCap < p.vertices+count {
+			vertexCap = vertexCap*2 + count
+		}
+		for indexCap < p.indices+len(indices) {
+			indexCap = indexCap*2 + len(indices)
+		}
+		p.grow(vertexCap, indexCap)
+	}
+
+	stride := p.Layout.Stride
+	if count > 0 {
+		gl.BindBuffer(gl.COPY_WRITE_BUFFER, p.Vbo)
+		gl.BufferSubData(gl.COPY_WRITE_BUFFER, p.vertices*stride, count*stride, vertices)
+	}
+	if len(indices) > 0 {
+		gl.BindBuffer(gl.COPY_WRITE_BUFFER, p.Ebo)
+		gl.BufferSubData(gl.COPY_WRITE_BUFFER, p.indices*4, len(indices)*4, gl.Ptr(indices))
+	}
+	gl.BindBuffer(gl.COPY_WRITE_BUFFER, 0)
+
+	r := GeometryRange{
+		BaseVertex:  int32(p.vertices),
+		FirstIndex:  int32(p.indices),
+		IndexCount:  int32(len(indices)),
+		VertexCount: int32(count),
+	}
+	p.vertices += count
+	p.indices += len(indices)
+	return r, nil
+}
+
+// Reset forgets every range, keeping the buffers to add meshes again.
+func (p *GeometryPool) Reset() {
+	p.vertices, p.indices = 0, 0
+}
+
+// SetPrimitive sets the type of primitive drawn, gl.POINTS included.
+func (p *GeometryPool) SetPrimitive(mode uint32) {
+	p.Primitive, p.primitiveSet = mode, true
+}
+
+func (p *GeometryPool) primitive() uint32 {
+	if p.primitiveSet || p.Primitive != 0 {
+		return p.Primitive
+	}
+	return gl.TRIANGLES
+}
+
+// Draw draws a single range.
+func (p *GeometryPool) Draw(r GeometryRange) {
+	gl.BindVertexArray(p.Vao)
+	gl.DrawElementsBaseVertex(p.primitive(), r.IndexCount, gl.UNSIGNED_INT, gl.PtrOffset(int(r.FirstIndex)*4), r.BaseVertex)
+	gl.BindVertexArray(0)
+}
+
+// DrawInstanced draws a range instances times.
+func (p *GeometryPool) DrawInstanced(r GeometryRange, instances int32) {
+	gl.BindVertexArray(p.Vao)
+	gl.DrawElementsInstancedBaseVertex(p.primitive(), r.IndexCount, gl.UNSIGNED_INT, gl.PtrOffset(int(r.FirstIndex)*4), instances, r.BaseVertex)
+	gl.BindVertexArray(0)
+}
+
+// DrawBatch draws every range of the batch with a single call.
+func (p *GeometryPool) DrawBatch(b *GeometryBatch) {
+	if len(b.counts) == 0 {
+		return
+	}
+	gl.BindVertexArray(p.Vao)
+	gl.MultiDrawElementsBaseVertex(p.primitive(), &b.counts[0], gl.UNSIGNED_INT, &b.offsets[0], int32(len(b.counts)), &b.baseVertices[0])
+	gl.BindVertexArray(0)
+}
+
+func (p *GeometryPool) Delete() {
+	gl.DeleteVertexArrays(1, &p.Vao)
+	gl.DeleteBuffers(1, &p.Vbo)
+	gl.DeleteBuffers(1, &p.Ebo)
+}
+
+// GeometryBatch is a list of ranges of a pool drawn together, typically all
+// the meshes using the same shader and textures.
+type GeometryBatch struct {
+	counts       []int32
+	offsets      []unsafe.Pointer
+	baseVertices []int32
+}
+
+func (b *GeometryBatch) Add(r GeometryRange) {
+	b.counts = append(b.counts, r.IndexCount)
+	b.offsets = append(b.offsets, gl.PtrOffset(int(r.FirstIndex)*4))
+	b.baseVertices = append(b.baseVertices, r.BaseVertex)
+}
+
+func (b *GeometryBatch) Len() int {
+	return len(b.counts)
+}
+
+func (b *GeometryBatch) Reset() {
+	b.counts = b.counts[:0]
+	b.offsets = b.offsets[:0]
+	b.baseVertices = b.baseVertices[:0]
+}
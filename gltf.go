@@ -0,0 +1,820 @@
+package glutils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// GLTF is the content of a glTF 2.0 file: the node tree of its default scene,
+// the meshes it draws and their materials.
+// Meshes are in mesh space, placed by the nodes of Root, and a glTF mesh
+// drawn by several nodes is stored once. Each triangle or point primitive is
+// a Mesh. Their textures are the material maps, texture_diffuse for the base
+// color, texture_metallic_roughness, texture_normal, texture_occlusion and
+// texture_emissive. External images keep their URI as Path, embedded images
+// are in Data with a Path of the form "image3". The Material of meshes is
+// the GLTFMaterial approximation for Phong shading.
+type GLTF struct {
+	// Root is an unnamed node whose children are the root nodes of the
+	// scene. Nodes keep their name and local transform.
+	Root      *Node
+	Meshes    []Mesh
+	Materials []GLTFMaterial
+	// MeshMaterials is the index in Materials of the material of each mesh,
+	// -1 for the default material.
+	MeshMaterials []int
+}
+
+// GLTFMaterial is a metallic-roughness PBR material. Absent texture maps are
+// nil.
+type GLTFMaterial struct {
+	Name            string
+	BaseColorFactor mgl32.Vec4
+	MetallicFactor  float32
+	RoughnessFactor float32
+	EmissiveFactor  mgl32.Vec3
+	NormalScale     float32
+	OcclusionScale  float32
+	AlphaMode       string
+	AlphaCutoff     float32
+	DoubleSided     bool
+
+	BaseColorTexture         *Texture
+	MetallicRoughnessTexture *Texture
+	NormalTexture            *Texture
+	OcclusionTexture         *Texture
+	EmissiveTexture          *Texture
+}
+
+// DefaultGLTFMaterial is the material of primitives without one.
+var DefaultGLTFMaterial = GLTFMaterial{
+	BaseColorFactor: mgl32.Vec4{1, 1, 1, 1},
+	MetallicFactor:  1,
+	RoughnessFactor: 1,
+	NormalScale:     1,
+	OcclusionScale:  1,
+	AlphaMode:       "OPAQUE",
+	AlphaCutoff:     0.5,
+}
+
+// Textures returns the texture maps of the material.
+func (mt GLTFMaterial) Textures() []Texture {
+	var textures []Texture
+	for _, t := range []*Texture{
+		mt.BaseColorTexture,
+		mt.MetallicRoughnessTexture,
+		mt.NormalTexture,
+		mt.OcclusionTexture,
+		mt.EmissiveTexture,
+	} {
+		if t != nil {
+			textures = append(textures, *t)
+		}
+	}
+	return textures
+}
+
+type gltfTextureInfo struct {
+	Index    int      `json:"index"`
+	TexCoord int      `json:"texCoord"`
+	Scale    *float32 `json:"scale"`
+	Strength *float32 `json:"strength"`
+}
+
+type gltfDoc struct {
+	Asset struct {
+		Version string `json:"version"`
+	} `json:"asset"`
+	Scene  *int `json:"scene"`
+	Scenes []struct {
+		Nodes []int `json:"nodes"`
+	} `json:"scenes"`
+	Nodes []struct {
+		Name        string    `json:"name"`
+		Children    []int     `json:"children"`
+		Mesh        *int      `json:"mesh"`
+		Matrix      []float32 `json:"matrix"`
+		Translation []float32 `json:"translation"`
+		Rotation    []float32 `json:"rotation"`
+		Scale       []float32 `json:"scale"`
+	} `json:"nodes"`
+	Meshes []struct {
+		Name       string `json:"name"`
+		Primitives []struct {
+			Attributes map[string]int `json:"attributes"`
+			Indices    *int           `json:"indices"`
+			Material   *int           `json:"material"`
+			Mode       *int           `json:"mode"`
+		} `json:"primitives"`
+	} `json:"meshes"`
+	Accessors   []gltfAccessor `json:"accessors"`
+	BufferViews []struct {
+		Buffer     int `json:"buffer"`
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+		ByteStride int `json:"byteStride"`
+	} `json:"bufferViews"`
+	Buffers []struct {
+		URI        string `json:"uri"`
+		ByteLength int    `json:"byteLength"`
+	} `json:"buffers"`
+	Materials []struct {
+		Name                 string `json:"name"`
+		PbrMetallicRoughness *struct {
+			BaseColorFactor          []float32        `json:"baseColorFactor"`
+			BaseColorTexture         *gltfTextureInfo `json:"baseColorTexture"`
+			MetallicFactor           *float32         `json:"metallicFactor"`
+			RoughnessFactor          *float32         `json:"roughnessFactor"`
+			MetallicRoughnessTexture *gltfTextureInfo `json:"metallicRoughnessTexture"`
+		} `json:"pbrMetallicRoughness"`
+		NormalTexture    *gltfTextureInfo `json:"normalTexture"`
+		OcclusionTexture *gltfTextureInfo `json:"occlusionTexture"`
+		EmissiveTexture  *gltfTextureInfo `json:"emissiveTexture"`
+		EmissiveFactor   []float32        `json:"emissiveFactor"`
+		AlphaMode        string           `json:"alphaMode"`
+		AlphaCutoff      *float32         `json:"alphaCutoff"`
+		DoubleSided      bool             `json:"doubleSided"`
+	} `json:"materials"`
+	Textures []struct {
+		Source *int `json:"source"`
+	} `json:"textures"`
+	Images []struct {
+		URI        string `json:"uri"`
+		BufferView *int   `json:"bufferView"`
+		MimeType   string `json:"mimeType"`
+	} `json:"images"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int   `json:"bufferView"`
+	ByteOffset    int    `json:"byteOffset"`
+	ComponentType int    `json:"componentType"`
+	Normalized    bool   `json:"normalized"`
+	Count         int    `json:"count"`
+	Type          string `json:"type"`
+	Sparse        *struct {
+		Count   int `json:"count"`
+		Indices struct {
+			BufferView    int `json:"bufferView"`
+			ByteOffset    int `json:"byteOffset"`
+			ComponentType int `json:"componentType"`
+		} `json:"indices"`
+		Values struct {
+			BufferView int `json:"bufferView"`
+			ByteOffset int `json:"byteOffset"`
+		} `json:"values"`
+	} `json:"sparse"`
+}
+
+const (
+	gltfByte          = 5120
+	gltfUnsignedByte  = 5121
+	gltfShort         = 5122
+	gltfUnsignedShort = 5123
+	gltfUnsignedInt   = 5125
+	gltfFloat         = 5126
+)
+
+// gltfParser holds a document and its loaded buffers.
+type gltfParser struct {
+	doc     gltfDoc
+	buffers [][]byte
+	open    func(uri string) ([]byte, error)
+	// phong is the Material of each material, shared by its meshes
+	phong []*Material
+	// meshes is the indices in GLTF.Meshes of the primitives of each parsed
+	// mesh
+	meshes map[int][]int
+}
+
+// ParseGLTF reads a glTF 2.0 file, JSON or binary (.glb). open reads the
+// external buffers and is given their URI relative to the file; it may be
+// nil when every buffer is embedded. No GL calls are made, meshes are set up
+// by Model.
+func ParseGLTF(data []byte, open func(uri string) ([]byte, error)) (*GLTF, error) {
+	p := &gltfParser{open: open, meshes: make(map[int][]int)}
+	var bin []byte
+	if bytes.HasPrefix(data, []byte("glTF")) {
+		var err error
+		data, bin, err = splitGLB(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(data, &p.doc); err != nil {
+		return nil, fmt.Errorf("gltf: %v", err)
+	}
+	if !strings.HasPrefix(p.doc.Asset.Version, "2.") {
+		return nil, fmt.Errorf("gltf: unsupported version %q", p.doc.Asset.Version)
+	}
+	if err := p.loadBuffers(bin); err != nil {
+		return nil, err
+	}
+
+	materials, err := p.materials()
+	if err != nil {
+		return nil, err
+	}
+	g := &GLTF{Materials: materials}
+	for _, mt := range materials {
+		p.phong = append(p.phong, mt.Material())
+	}
+
+	var roots []int
+	switch {
+	case p.doc.Scene != nil && *p.doc.Scene < len(p.doc.Scenes):
+		roots = p.doc.Scenes[*p.doc.Scene].Nodes
+	case len(p.doc.Scenes) > 0:
+		roots = p.doc.Scenes[0].Nodes
+	default:
+		// no scene, every node that is not a child is a root
+		child := make(map[int]bool)
+		for _, n := range p.doc.Nodes {
+			for _, c := range n.Children {
+				child[c] = true
+			}
+		}
+		for i := range p.doc.Nodes {
+			if !child[i] {
+				roots = append(roots, i)
+			}
+		}
+	}
+	g.Root = NewNode("", mgl32.Ident4())
+	for _, n := range roots {
+		node, err := p.node(g, n, 0)
+		if err != nil {
+			return nil, err
+		}
+		g.Root.AddChild(node)
+	}
+	return g, nil
+}
+
+// splitGLB returns the JSON and binary chunks of a GLB file.
+func splitGLB(data []byte) ([]byte, []byte, error) {
+	if len(data) < 20 {
+		return nil, nil, fmt.Errorf("gltf: truncated glb header")
+	}
+	if v := binary.LittleEndian.Uint32(data[4:]); v != 2 {
+		return nil, nil, fmt.Errorf("gltf: unsupported glb version %d", v)
+	}
+	length := int(binary.LittleEndian.Uint32(data[8:]))
+	if length > len(data) {
+		return nil, nil, fmt.Errorf("gltf: glb is %d bytes, header says %d", len(data), length)
+	}
+	var jsonChunk, binChunk []byte
+	for off := 12; off+8 <= length; {
+		size := int(binary.LittleEndian.Uint32(data[off:]))
+		kind := string(data[off+4 : off+8])
+		off += 8
+		if off+size > length {
+			return nil, nil, fmt.Errorf("gltf: truncated glb chunk %q", kind)
+		}
+		switch kind {
+		case "JSON":
+			jsonChunk = data[off : off+size]
+		case "BIN\x00":
+			if binChunk == nil {
+				binChunk = data[off : off+size]
+			}
+		}
+		off += roundUp(size, 4)
+	}
+	if jsonChunk == nil {
+		return nil, nil, fmt.Errorf("gltf: glb has no JSON chunk")
+	}
+	return jsonChunk, binChunk, nil
+}
+
+func (p *gltfParser) loadBuffers(bin []byte) error {
+	p.buffers = make([][]byte, len(p.doc.Buffers))
+	for i, b := range p.doc.Buffers {
+		var (
+			data []byte
+			err  error
+		)
+		switch {
+		case b.URI == "" && i == 0 && bin != nil:
+			data = bin
+		case b.URI == "":
+			err = fmt.Errorf("no uri")
+		default:
+			data, err = p.uri(b.URI)
+		}
+		if err == nil && len(data) < b.ByteLength {
+			err = fmt.Errorf("%d bytes, expected %d", len(data), b.ByteLength)
+		}
+		if err != nil {
+			return fmt.Errorf("gltf: buffer %d: %v", i, err)
+		}
+		p.buffers[i] = data
+	}
+	return nil
+}
+
+// uri reads a data URI or an external file.
+func (p *gltfParser) uri(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		i := strings.IndexByte(uri, ',')
+		if i < 0 || !strings.HasSuffix(uri[:i], ";base64") {
+			return nil, fmt.Errorf("unsupported data uri")
+		}
+		return base64.StdEncoding.DecodeString(uri[i+1:])
+	}
+	if p.open == nil {
+		return nil, fmt.Errorf("external file %q", uri)
+	}
+	name, err := url.PathUnescape(uri)
+	if err != nil {
+		name = uri
+	}
+	return p.open(name)
+}
+
+func (p *gltfParser) bufferView(i int) ([]byte, int, error) {
+	if i < 0 || i >= len(p.doc.BufferViews) {
+		return nil, 0, fmt.Errorf("buffer view %d out of range", i)
+	}
+	v := p.doc.BufferViews[i]
+	if v.Buffer < 0 || v.Buffer >= len(p.buffers) {
+		return nil, 0, fmt.Errorf("buffer %d out of range", v.Buffer)
+	}
+	b := p.buffers[v.Buffer]
+	if v.ByteOffset < 0 || v.ByteLength < 0 {
+		return nil, 0, fmt.Errorf("buffer view %d has a negative offset or length", i)
+	}
+	if v.ByteStride < 0 || v.ByteStride > 252 {
+		return nil, 0, fmt.Errorf("buffer view %d: stride %d out of range", i, v.ByteStride)
+	}
+	if v.ByteOffset > len(b) || v.ByteLength > len(b)-v.ByteOffset {
+		return nil, 0, fmt.Errorf("buffer view %d out of buffer %d", i, v.Buffer)
+	}
+	return b[v.ByteOffset : v.ByteOffset+v.ByteLength], v.ByteStride, nil
+}
+
+func gltfComponents(t string) int {
+	switch t {
+	case "SCALAR":
+		return 1
+	case "VEC2":
+		return 2
+	case "VEC3":
+		return 3
+	case "VEC4", "MAT2":
+		return 4
+	case "MAT3":
+		return 9
+	case "MAT4":
+		return 16
+	}
+	return 0
+}
+
+func gltfComponentSize(ct int) int {
+	switch ct {
+	case gltfByte, gltfUnsignedByte:
+		return 1
+	case gltfShort, gltfUnsignedShort:
+		return 2
+	case gltfUnsignedInt, gltfFloat:
+		return 4
+	}
+	return 0
+}
+
+// gltfComponent reads a component, normalizing integers to [0, 1] or [-1, 1]
+// when normalized is set.
+func gltfComponent(b []byte, ct int, normalized bool) float32 {
+	switch ct {
+	case gltfByte:
+		v := float32(int8(b[0]))
+		if normalized {
+			return float32(math.Max(float64(v/127), -1))
+		}
+		return v
+	case gltfUnsignedByte:
+		v := float32(b[0])
+		if normalized {
+			return v / 255
+		}
+		return v
+	case gltfShort:
+		v := float32(int16(binary.LittleEndian.Uint16(b)))
+		if normalized {
+			return float32(math.Max(float64(v/32767), -1))
+		}
+		return v
+	case gltfUnsignedShort:
+		v := float32(binary.LittleEndian.Uint16(b))
+		if normalized {
+			return v / 65535
+		}
+		return v
+	case gltfUnsignedInt:
+		return float32(binary.LittleEndian.Uint32(b))
+	case gltfFloat:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	}
+	return 0
+}
+
+// accessor returns the elements of an accessor as floats, components per
+// element, with sparse values applied.
+func (p *gltfParser) accessor(i int) ([]float32, int, error) {
+	if i < 0 || i >= len(p.doc.Accessors) {
+		return nil, 0, fmt.Errorf("gltf: accessor %d out of range", i)
+	}
+	a := p.doc.Accessors[i]
+	n := gltfComponents(a.Type)
+	size := gltfComponentSize(a.ComponentType)
+	if n == 0 || size == 0 {
+		return nil, 0, fmt.Errorf("gltf: accessor %d: unsupported type %s of %d", i, a.Type, a.ComponentType)
+	}
+	if a.Count < 0 || a.ByteOffset < 0 {
+		return nil, 0, fmt.Errorf("gltf: accessor %d: negative count or offset", i)
+	}
+
+	var (
+		view   []byte
+		stride int
+	)
+	if a.BufferView != nil {
+		var err error
+		view, stride, err = p.bufferView(*a.BufferView)
+		if err != nil {
+			return nil, 0, fmt.Errorf("gltf: accessor %d: %v", i, err)
+		}
+		if stride == 0 {
+			stride = n * size
+		}
+		// every element takes at least a byte, which also bounds the
+		// products below
+		if a.Count > len(view) || a.ByteOffset > len(view) ||
+			a.Count > 0 && a.ByteOffset+(a.Count-1)*stride+n*size > len(view) {
+			return nil, 0, fmt.Errorf("gltf: accessor %d: out of its buffer view", i)
+		}
+	}
+	out := make([]float32, a.Count*n)
+	if a.BufferView != nil {
+		for e := 0; e < a.Count; e++ {
+			off := a.ByteOffset + e*stride
+			for c := 0; c < n; c++ {
+				out[e*n+c] = gltfComponent(view[off+c*size:], a.ComponentType, a.Normalized)
+			}
+		}
+	}
+
+	if s := a.Sparse; s != nil && s.Count > 0 {
+		indices, _, err := p.bufferView(s.Indices.BufferView)
+		if err != nil {
+			return nil, 0, fmt.Errorf("gltf: accessor %d: sparse indices: %v", i, err)
+		}
+		values, _, err := p.bufferView(s.Values.BufferView)
+		if err != nil {
+			return nil, 0, fmt.Errorf("gltf: accessor %d: sparse values: %v", i, err)
+		}
+		isize := gltfComponentSize(s.Indices.ComponentType)
+		if isize == 0 || s.Indices.ByteOffset < 0 || s.Values.ByteOffset < 0 ||
+			s.Count > len(indices) || s.Count > len(values) ||
+			s.Indices.ByteOffset+s.Count*isize > len(indices) ||
+			s.Values.ByteOffset+s.Count*n*size > len(values) {
+			return nil, 0, fmt.Errorf("gltf: accessor %d: sparse data out of its buffer views", i)
+		}
+		for k := 0; k < s.Count; k++ {
+			e := int(gltfComponent(indices[s.Indices.ByteOffset+k*isize:], s.Indices.ComponentType, false))
+			if e < 0 || e >= a.Count {
+				return nil, 0, fmt.Errorf("gltf: accessor %d: sparse index %d out of range", i, e)
+			}
+			for c := 0; c < n; c++ {
+				out[e*n+c] = gltfComponent(values[s.Values.ByteOffset+(k*n+c)*size:], a.ComponentType, a.Normalized)
+			}
+		}
+	}
+	return out, n, nil
+}
+
+func (p *gltfParser) texture(info *gltfTextureInfo) (*Texture, error) {
+	if info == nil {
+		return nil, nil
+	}
+	if info.Index < 0 || info.Index >= len(p.doc.Textures) {
+		return nil, fmt.Errorf("gltf: texture %d out of range", info.Index)
+	}
+	src := p.doc.Textures[info.Index].Source
+	if src == nil {
+		return nil, nil
+	}
+	if *src < 0 || *src >= len(p.doc.Images) {
+		return nil, fmt.Errorf("gltf: image %d out of range", *src)
+	}
+	img := p.doc.Images[*src]
+	t := &Texture{Path: fmt.Sprintf("image%d", *src)}
+	switch {
+	case img.BufferView != nil:
+		data, _, err := p.bufferView(*img.BufferView)
+		if err != nil {
+			return nil, fmt.Errorf("gltf: image %d: %v", *src, err)
+		}
+		t.Data = data
+	case strings.HasPrefix(img.URI, "data:"):
+		data, err := p.uri(img.URI)
+		if err != nil {
+			return nil, fmt.Errorf("gltf: image %d: %v", *src, err)
+		}
+		t.Data = data
+	default:
+		name, err := url.PathUnescape(img.URI)
+		if err != nil {
+			name = img.URI
+		}
+		t.Path = name
+	}
+	return t, nil
+}
+
+func (p *gltfParser) materials() ([]GLTFMaterial, error) {
+	var materials []GLTFMaterial
+	for _, m := range p.doc.Materials {
+		mt := DefaultGLTFMaterial
+		mt.Name = m.Name
+		var err error
+		tex := func(info *gltfTextureInfo, textureType string) *Texture {
+			t, e := p.texture(info)
+			if e != nil && err == nil {
+				err = e
+			}
+			if t != nil {
+				t.TextureType = textureType
+			}
+			return t
+		}
+		if pbr := m.PbrMetallicRoughness; pbr != nil {
+			if len(pbr.BaseColorFactor) == 4 {
+				copy(mt.BaseColorFactor[:], pbr.BaseColorFactor)
+			}
+			if pbr.MetallicFactor != nil {
+				mt.MetallicFactor = *pbr.MetallicFactor
+			}
+			if pbr.RoughnessFactor != nil {
+				mt.RoughnessFactor = *pbr.RoughnessFactor
+			}
+			mt.BaseColorTexture = tex(pbr.BaseColorTexture, "texture_diffuse")
+			mt.MetallicRoughnessTexture = tex(pbr.MetallicRoughnessTexture, "texture_metallic_roughness")
+		}
+		mt.NormalTexture = tex(m.NormalTexture, "texture_normal")
+		if m.NormalTexture != nil && m.NormalTexture.Scale != nil {
+			mt.NormalScale = *m.NormalTexture.Scale
+		}
+		mt.OcclusionTexture = tex(m.OcclusionTexture, "texture_occlusion")
+		if m.OcclusionTexture != nil && m.OcclusionTexture.Strength != nil {
+			mt.OcclusionScale = *m.OcclusionTexture.Strength
+		}
+		mt.EmissiveTexture = tex(m.EmissiveTexture, "texture_emissive")
+		if len(m.EmissiveFactor) == 3 {
+			copy(mt.EmissiveFactor[:], m.EmissiveFactor)
+		}
+		if m.AlphaMode != "" {
+			mt.AlphaMode = m.AlphaMode
+		}
+		if m.AlphaCutoff != nil {
+			mt.AlphaCutoff = *m.AlphaCutoff
+		}
+		mt.DoubleSided = m.DoubleSided
+		if err != nil {
+			return nil, err
+		}
+		materials = append(materials, mt)
+	}
+	return materials, nil
+}
+
+// nodeMatrix returns the local transform of a node.
+func (p *gltfParser) nodeMatrix(i int) mgl32.Mat4 {
+	n := p.doc.Nodes[i]
+	if len(n.Matrix) == 16 {
+		var m mgl32.Mat4
+		copy(m[:], n.Matrix)
+		return m
+	}
+	m := mgl32.Ident4()
+	if len(n.Translation) == 3 {
+		m = mgl32.Translate3D(n.Translation[0], n.Translation[1], n.Translation[2])
+	}
+	if len(n.Rotation) == 4 {
+		q := mgl32.Quat{W: n.Rotation[3], V: mgl32.Vec3{n.Rotation[0], n.Rotation[1], n.Rotation[2]}}
+		m = m.Mul4(q.Normalize().Mat4())
+	}
+	if len(n.Scale) == 3 {
+		m = m.Mul4(mgl32.Scale3D(n.Scale[0], n.Scale[1], n.Scale[2]))
+	}
+	return m
+}
+
+// node returns the tree of node i.
+func (p *gltfParser) node(g *GLTF, i, depth int) (*Node, error) {
+	if i < 0 || i >= len(p.doc.Nodes) {
+		return nil, fmt.Errorf("gltf: node %d out of range", i)
+	}
+	if depth > len(p.doc.Nodes) {
+		return nil, fmt.Errorf("gltf: node %d is part of a cycle", i)
+	}
+	n := p.doc.Nodes[i]
+	node := NewNode(n.Name, p.nodeMatrix(i))
+	if n.Mesh != nil {
+		meshes, err := p.mesh(g, *n.Mesh)
+		if err != nil {
+			return nil, err
+		}
+		node.Meshes = meshes
+	}
+	for _, c := range n.Children {
+		child, err := p.node(g, c, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		node.AddChild(child)
+	}
+	return node, nil
+}
+
+// mesh appends the triangle primitives of mesh i the first time it is drawn,
+// and returns their indices in g.Meshes.
+func (p *gltfParser) mesh(g *GLTF, i int) ([]int, error) {
+	if i < 0 || i >= len(p.doc.Meshes) {
+		return nil, fmt.Errorf("gltf: mesh %d out of range", i)
+	}
+	if meshes, ok := p.meshes[i]; ok {
+		return meshes, nil
+	}
+	var meshes []int
+	for pi, prim := range p.doc.Meshes[i].Primitives {
+		mode := 4
+		if prim.Mode != nil {
+			mode = *prim.Mode
+		}
+		if mode > 0 && mode < 4 {
+			// lines can't be drawn by Mesh
+			continue
+		}
+		pos, ok := prim.Attributes["POSITION"]
+		if !ok {
+			continue
+		}
+		positions, n, err := p.accessor(pos)
+		if err != nil {
+			return nil, err
+		}
+		if n != 3 {
+			return nil, fmt.Errorf("gltf: mesh %d primitive %d: POSITION is not a VEC3", i, pi)
+		}
+		count := len(positions) / 3
+
+		attribute := func(name string, comps int) ([]float32, error) {
+			a, ok := prim.Attributes[name]
+			if !ok {
+				return nil, nil
+			}
+			data, n, err := p.accessor(a)
+			if err == nil && (n != comps || len(data) != count*n) {
+				err = fmt.Errorf("gltf: mesh %d primitive %d: unexpected %s layout", i, pi, name)
+			}
+			return data, err
+		}
+		normals, err := attribute("NORMAL", 3)
+		if err != nil {
+			return nil, err
+		}
+		texCoords, err := attribute("TEXCOORD_0", 2)
+		if err != nil {
+			return nil, err
+		}
+		tangents, err := attribute("TANGENT", 4)
+		if err != nil {
+			return nil, err
+		}
+
+		var indices []uint32
+		if prim.Indices != nil {
+			data, _, err := p.accessor(*prim.Indices)
+			if err != nil {
+				return nil, err
+			}
+			indices = make([]uint32, len(data))
+			for k, f := range data {
+				if f < 0 || int(f) >= count {
+					return nil, fmt.Errorf("gltf: mesh %d primitive %d: index %d out of %d vertices", i, pi, int(f), count)
+				}
+				indices[k] = uint32(f)
+			}
+		} else {
+			indices = make([]uint32, count)
+			for k := range indices {
+				indices[k] = uint32(k)
+			}
+		}
+		if mode != 0 {
+			indices = gltfTriangles(mode, indices)
+		}
+
+		vertices := make([]Vertex, count)
+		for v := range vertices {
+			vertex := &vertices[v]
+			vertex.Position = mgl32.Vec3{positions[v*3], positions[v*3+1], positions[v*3+2]}
+			if normals != nil {
+				vertex.Normal = mgl32.Vec3{normals[v*3], normals[v*3+1], normals[v*3+2]}
+				if vertex.Normal.Len() > 0 {
+					vertex.Normal = vertex.Normal.Normalize()
+				}
+			}
+			if texCoords != nil {
+				vertex.TexCoords = mgl32.Vec2{texCoords[v*2], texCoords[v*2+1]}
+			}
+			if tangents != nil {
+				t := mgl32.Vec3{tangents[v*4], tangents[v*4+1], tangents[v*4+2]}
+				if t.Len() > 0 {
+					t = t.Normalize()
+				}
+				vertex.Tangent = t
+				vertex.Bitangent = vertex.Normal.Cross(t).Mul(tangents[v*4+3])
+			}
+		}
+		if normals == nil && mode != 0 {
+			// flat normals, as the specification requires
+			vertices, indices = flatNormals(vertices, indices)
+		}
+
+		mat := -1
+		var textures []Texture
+		if prim.Material != nil {
+			mat = *prim.Material
+			if mat < 0 || mat >= len(g.Materials) {
+				return nil, fmt.Errorf("gltf: mesh %d primitive %d: material %d out of range", i, pi, mat)
+			}
+			textures = g.Materials[mat].Textures()
+		}
+		mesh := NewMesh(vertices, indices, textures)
+		mesh.Id = len(g.Meshes)
+		mesh.Points = mode == 0
+		if mat >= 0 {
+			mesh.Material = p.phong[mat]
+		}
+		meshes = append(meshes, len(g.Meshes))
+		g.Meshes = append(g.Meshes, mesh)
+		g.MeshMaterials = append(g.MeshMaterials, mat)
+	}
+	p.meshes[i] = meshes
+	return meshes, nil
+}
+
+// gltfTriangles converts strip and fan indices to a triangle list.
+func gltfTriangles(mode int, indices []uint32) []uint32 {
+	switch mode {
+	case 5:
+		var tris []uint32
+		for k := 0; k+2 < len(indices); k++ {
+			if k%2 == 0 {
+				tris = append(tris, indices[k], indices[k+1], indices[k+2])
+			} else {
+				tris = append(tris, indices[k+1], indices[k], indices[k+2])
+			}
+		}
+		return tris
+	case 6:
+		var tris []uint32
+		for k := 1; k+1 < len(indices); k++ {
+			tris = append(tris, indices[0], indices[k], indices[k+1])
+		}
+		return tris
+	}
+	return indices[:len(indices)/3*3]
+}
+
+// flatNormals unwelds the triangles and gives their vertices the face normal.
+func flatNormals(vertices []Vertex, indices []uint32) ([]Vertex, []uint32) {
+	flat := make([]Vertex, len(indices))
+	flatIndices := make([]uint32, len(indices))
+	for k := 0; k+2 < len(indices); k += 3 {
+		a, b, c := vertices[indices[k]], vertices[indices[k+1]], vertices[indices[k+2]]
+		n := b.Position.Sub(a.Position).Cross(c.Position.Sub(a.Position))
+		if n.Len() > 0 {
+			n = n.Normalize()
+		}
+		for j, v := range []Vertex{a, b, c} {
+			v.Normal = n
+			flat[k+j] = v
+			flatIndices[k+j] = uint32(k + j)
+		}
+	}
+	return flat, flatIndices
+}
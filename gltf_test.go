@@ -0,0 +1,113 @@
+package glutils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// testGLTF is a triangle mesh drawn by two nodes under a translated parent.
+func testGLTF() []byte {
+	return testGLTFWith(`{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"}`, `{"buffer": 0, "byteLength": 36}`)
+}
+
+// testGLTFWith is testGLTF with the POSITION accessor and its buffer view
+// replaced.
+func testGLTFWith(accessor, bufferView string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, []float32{0, 0, 0, 1, 0, 0, 0, 1, 0})
+	return []byte(fmt.Sprintf(`{
+	"asset": {"version": "2.0"},
+	"scene": 0,
+	"scenes": [{"nodes": [0]}],
+	"nodes": [
+		{"name": "parent", "translation": [1, 2, 3], "children": [1, 2]},
+		{"name": "left", "mesh": 0},
+		{"name": "mirrored", "mesh": 0, "scale": [-1, 1, 1]}
+	],
+	"meshes": [{"primitives": [{"attributes": {"POSITION": 0}}]}],
+	"accessors": [%s],
+	"bufferViews": [%s],
+	"buffers": [{"byteLength": 36, "uri": "data:application/octet-stream;base64,%s"}]
+}`, accessor, bufferView, base64.StdEncoding.EncodeToString(buf.Bytes())))
+}
+
+func TestParseGLTFNodes(t *testing.T) {
+	g, err := ParseGLTF(testGLTF(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Meshes) != 1 {
+		t.Fatalf("got %d meshes, want the shared mesh once", len(g.Meshes))
+	}
+	for i, want := range []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}} {
+		if got := g.Meshes[0].Vertices[i].Position; got != want {
+			t.Errorf("vertex %d at %v, want %v in mesh space", i, got, want)
+		}
+	}
+	if len(g.Root.Children) != 1 || g.Root.Children[0].Name != "parent" {
+		t.Fatalf("root children %v", g.Root.Children)
+	}
+	for _, name := range []string{"left", "mirrored"} {
+		n := g.Root.Find(name)
+		if n == nil {
+			t.Fatalf("no node %q", name)
+		}
+		if len(n.Meshes) != 1 || n.Meshes[0] != 0 {
+			t.Errorf("node %q draws %v, want [0]", name, n.Meshes)
+		}
+		if got := n.WorldMatrix().Col(3).Vec3(); got != (mgl32.Vec3{1, 2, 3}) {
+			t.Errorf("node %q at %v, want [1 2 3]", name, got)
+		}
+	}
+	if got := g.Root.Find("mirrored").Transform; got != mgl32.Scale3D(-1, 1, 1) {
+		t.Errorf("mirrored local transform %v", got)
+	}
+}
+
+func TestParseGLTFCycle(t *testing.T) {
+	_, err := ParseGLTF([]byte(`{
+	"asset": {"version": "2.0"},
+	"scenes": [{"nodes": [0]}],
+	"nodes": [{"children": [1]}, {"children": [0]}]
+}`), nil)
+	if err == nil {
+		t.Error("no error for a node cycle")
+	}
+}
+
+func TestParseGLTFMalformed(t *testing.T) {
+	const (
+		accessor = `{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"}`
+		view     = `{"buffer": 0, "byteLength": 36}`
+	)
+	tests := []struct {
+		name           string
+		accessor, view string
+	}{
+		{"negative view offset", accessor, `{"buffer": 0, "byteOffset": -12, "byteLength": 36}`},
+		{"negative view length", accessor, `{"buffer": 0, "byteLength": -1}`},
+		{"negative view stride", accessor, `{"buffer": 0, "byteLength": 36, "byteStride": -12}`},
+		{"view past the buffer", accessor, `{"buffer": 0, "byteOffset": 12, "byteLength": 36}`},
+		{"huge view offset", accessor, `{"buffer": 0, "byteOffset": 9000000000000000000, "byteLength": 36}`},
+		{"negative accessor offset", `{"bufferView": 0, "byteOffset": -12, "componentType": 5126, "count": 3, "type": "VEC3"}`, view},
+		{"negative count", `{"bufferView": 0, "componentType": 5126, "count": -1, "type": "VEC3"}`, view},
+		{"huge count", `{"bufferView": 0, "componentType": 5126, "count": 9000000000000000000, "type": "VEC3"}`, view},
+		{"count past the view", `{"bufferView": 0, "componentType": 5126, "count": 4, "type": "VEC3"}`, view},
+		{"negative sparse offset", `{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3",
+			"sparse": {"count": 1, "indices": {"bufferView": 0, "byteOffset": -4, "componentType": 5125},
+			"values": {"bufferView": 0}}}`, view},
+		{"negative sparse index", `{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3",
+			"sparse": {"count": 1, "indices": {"bufferView": 0, "byteOffset": 14, "componentType": 5120},
+			"values": {"bufferView": 0}}}`, view},
+	}
+	for _, tt := range tests {
+		if _, err := ParseGLTF(testGLTFWith(tt.accessor, tt.view), nil); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
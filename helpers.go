@@ -5,6 +5,10 @@ import (
 	"github.com/go-gl/mathgl/mgl32"
 	"fmt"
 	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
 )
 
 // checks if o implements i
@@ -23,6 +27,184 @@ func IsType(o, i interface{}) bool {
 	return a == b
 }
 
+// VertexLayoutOf builds the layout of a vertex struct from the gl tags of its
+// fields. v is a struct, a pointer to one or a slice of them.
+// A tag names the shader input, looked up in attributes (Shader.Attributes),
+// and/or gives its location: `gl:"position"`, `gl:"loc=2,normalized"`.
+// Named inputs missing from attributes were optimized out and are skipped.
+// Options are:
+//	normalized  integer fields are converted to normalized floats
+//	float       integer fields are converted to floats
+//	half        uint16 fields hold half floats
+//	packed      int32/uint32 fields hold 2_10_10_10 vectors
+// Other integer fields are integer inputs. Fields are float32, integers,
+// arrays of 1 to 4 of those, mgl32 vectors or mgl32 matrices, which take one
+// location per column. Untagged fields and fields tagged "-" are skipped.
+func VertexLayoutOf(v interface{}, attributes map[string]uint32) (*VertexLayout, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("vertex layout: expected a struct, got %T", v)
+	}
+	l := &VertexLayout{Stride: int(t.Size())}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("gl")
+		if !ok || tag == "-" {
+			continue
+		}
+		var (
+			name                          string
+			loc                           uint32
+			hasLoc                        bool
+			normalized, float, half, pack bool
+		)
+		for _, opt := range strings.Split(tag, ",") {
+			opt = strings.TrimSpace(opt)
+			switch {
+			case strings.HasPrefix(opt, "loc="):
+				n, err := strconv.ParseUint(opt[4:], 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("vertex layout: field %s: bad location %q", f.Name, opt[4:])
+				}
+				loc, hasLoc = uint32(n), true
+			case opt == "normalized":
+				normalized = true
+			case opt == "float":
+				float = true
+			case opt == "half":
+				half = true
+			case opt == "packed":
+				pack = true
+			case name == "" && opt != "":
+				name = opt
+			default:
+				return nil, fmt.Errorf("vertex layout: field %s: unknown option %q", f.Name, opt)
+			}
+		}
+		if !hasLoc {
+			if name == "" {
+				return nil, fmt.Errorf("vertex layout: field %s: tag has no name nor location", f.Name)
+			}
+			if attributes == nil {
+				return nil, fmt.Errorf("vertex layout: field %s: no attributes to look up %q", f.Name, name)
+			}
+			if loc, ok = attributes[name]; !ok {
+				continue
+			}
+		}
+
+		a, cols, err := fieldAttribute(f.Type, half, pack)
+		if err != nil {
+			return nil, fmt.Errorf("vertex layout: field %s: %v", f.Name, err)
+		}
+		if a.Integer && (normalized || float) {
+			a.Integer = false
+		}
+		a.Normalized = normalized
+		for c := 0; c < cols; c++ {
+			a.Location = loc + uint32(c)
+			a.Offset = int(f.Offset) + c*a.ByteSize()
+			l.Attributes = append(l.Attributes, a)
+		}
+	}
+	return l, l.Validate()
+}
+
+// fieldAttribute returns the attribute of a vertex struct field type, without
+// location nor offset, and the number of columns for matrices.
+func fieldAttribute(t reflect.Type, half, pack bool) (VertexAttribute, int, error) {
+	a := VertexAttribute{Size: 1}
+	cols := 1
+	switch t {
+	case mat2Type:
+		return VertexAttribute{Size: 2, Type: gl.FLOAT}, 2, nil
+	case mat3Type:
+		return VertexAttribute{Size: 3, Type: gl.FLOAT}, 3, nil
+	case mat4Type:
+		return VertexAttribute{Size: 4, Type: gl.FLOAT}, 4, nil
+	}
+	elem := t
+	if t.Kind() == reflect.Array {
+		a.Size = int32(t.Len())
+		elem = t.Elem()
+	}
+	switch elem.Kind() {
+	case reflect.Float32:
+		a.Type = gl.FLOAT
+	case reflect.Float64:
+		a.Type = gl.DOUBLE
+	case reflect.Int8:
+		a.Type, a.Integer = gl.BYTE, true
+	case reflect.Uint8:
+		a.Type, a.Integer = gl.UNSIGNED_BYTE, true
+	case reflect.Int16:
+		a.Type, a.Integer = gl.SHORT, true
+	case reflect.Uint16:
+		a.Type, a.Integer = gl.UNSIGNED_SHORT, true
+		if half {
+			a.Type, a.Integer = gl.HALF_FLOAT, false
+		}
+	case reflect.Int32:
+		a.Type, a.Integer = gl.INT, true
+		if pack {
+			a.Type, a.Size, a.Integer = gl.INT_2_10_10_10_REV, 4, false
+		}
+	case reflect.Uint32:
+		a.Type, a.Integer = gl.UNSIGNED_INT, true
+		if pack {
+			a.Type, a.Size, a.Integer = gl.UNSIGNED_INT_2_10_10_10_REV, 4, false
+		}
+	default:
+		return a, 0, fmt.Errorf("unsupported type %s", t)
+	}
+	if a.Size < 1 || a.Size > 4 {
+		return a, 0, fmt.Errorf("%s has %d components, at most 4 are supported", t, a.Size)
+	}
+	return a, cols, nil
+}
+
+// mustVertexLayoutOf is VertexLayoutOf for the vertex types of the package,
+// whose tags are fixed: it panics on error.
+func mustVertexLayoutOf(v interface{}) *VertexLayout {
+	l, err := VertexLayoutOf(v, nil)
+	if err != nil {
+		panic(err)
+	}
+	if err := l.Validate(); err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// NewVertexArrayOf builds and sets up a VertexArray from a slice of vertex
+// structs described by their gl tags, see VertexLayoutOf.
+func NewVertexArrayOf(vertices interface{}, indices []uint32, attributes map[string]uint32) (*VertexArray, error) {
+	rv := reflect.ValueOf(vertices)
+	if rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("vertex array: expected a slice of structs, got %T", vertices)
+	}
+	layout, err := VertexLayoutOf(vertices, attributes)
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]byte, rv.Len()*layout.Stride)
+	if len(raw) > 0 {
+		copy(raw, unsafe.Slice((*byte)(unsafe.Pointer(rv.Pointer())), len(raw)))
+	}
+	v := &VertexArray{
+		RawData: raw,
+		Indices: indices,
+		Layout:  layout,
+	}
+	if err := v.Setup(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
 func PrintMat4(m mgl32.Mat4) {
 	fmt.Printf("%s\n%s\n%s\n%s\n-------\n",
 		ftos([]float32{m[0], m[4], m[8], m[12]}),
@@ -0,0 +1,149 @@
+package glutils
+
+import (
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// InstanceBuffer is a vertex buffer of per-instance attributes, such as model
+// matrices or colors, attached to the VAOs of the geometry drawn instanced.
+// Divisor is the number of instances sharing an element, 1 by default.
+// The buffer can be attached to several VAOs and is not deleted with them.
+type InstanceBuffer struct {
+	Vbo     uint32
+	Layout  *VertexLayout
+	Divisor uint32
+	Usage   uint32
+	Count   int32
+	size    int
+}
+
+// NewInstanceBuffer allocates a buffer holding data described by layout, one
+// element per instance.
+func NewInstanceBuffer(layout *VertexLayout, data []byte) *InstanceBuffer {
+	b := &InstanceBuffer{Layout: layout, Divisor: 1, Usage: gl.DYNAMIC_DRAW}
+	gl.GenBuffers(1, &b.Vbo)
+	var ptr unsafe.Pointer
+	if len(data) > 0 {
+		ptr = gl.Ptr(data)
+	}
+	b.upload(len(data), ptr)
+	return b
+}
+
+// NewMat4InstanceBuffer allocates a buffer of one matrix per instance, read by
+// a mat4 vertex input at loc. A mat4 input takes the four locations from loc
+// to loc+3, one per column.
+func NewMat4InstanceBuffer(loc uint32, m []mgl32.Mat4) *InstanceBuffer {
+	b := NewInstanceBuffer(NewVertexLayout().Mat4(loc), nil)
+	b.UpdateMat4(m)
+	return b
+}
+
+// NewVec4InstanceBuffer allocates a buffer of one vec4 per instance, e.g. a
+// color, read by the vertex input at loc.
+func NewVec4InstanceBuffer(loc uint32, v []mgl32.Vec4) *InstanceBuffer {
+	b := NewInstanceBuffer(NewVertexLayout().Float(loc, 4, gl.FLOAT, false), nil)
+	b.UpdateVec4(v)
+	return b
+}
+
+// NewVec3InstanceBuffer allocates a buffer of one vec3 per instance, e.g. an
+// offset, read by the vertex input at loc.
+func NewVec3InstanceBuffer(loc uint32, v []mgl32.Vec3) *InstanceBuffer {
+	b := NewInstanceBuffer(NewVertexLayout().Float(loc, 3, gl.FLOAT, false), nil)
+	b.UpdateVec3(v)
+	return b
+}
+
+// Update replaces the contents of the buffer, orphaning the previous storage
+// so that draws still reading it don't stall the upload.
+func (b *InstanceBuffer) Update(data []byte) {
+	if len(data) == 0 {
+		b.upload(0, nil)
+		return
+	}
+	b.upload(len(data), gl.Ptr(data))
+}
+
+func (b *InstanceBuffer) UpdateMat4(m []mgl32.Mat4) {
+	if len(m) == 0 {
+		b.upload(0, nil)
+		return
+	}
+	b.upload(len(m)*16*GL_FLOAT32_SIZE, gl.Ptr(m))
+}
+
+func (b *InstanceBuffer) UpdateVec4(v []mgl32.Vec4) {
+	if len(v) == 0 {
+		b.upload(0, nil)
+		return
+	}
+	b.upload(len(v)*4*GL_FLOAT32_SIZE, gl.Ptr(v))
+}
+
+func (b *InstanceBuffer) UpdateVec3(v []mgl32.Vec3) {
+	if len(v) == 0 {
+		b.upload(0, nil)
+		return
+	}
+	b.upload(len(v)*3*GL_FLOAT32_SIZE, gl.Ptr(v))
+}
+
+func (b *InstanceBuffer) upload(size int, ptr unsafe.Pointer) {
+	gl.BindBuffer(gl.ARRAY_BUFFER, b.Vbo)
+	if size > b.size || b.size == 0 {
+		gl.BufferData(gl.ARRAY_BUFFER, size, ptr, b.Usage)
+		b.size = size
+	} else {
+		gl.BufferData(gl.ARRAY_BUFFER, b.size, nil, b.Usage)
+		if size > 0 {
+			gl.BufferSubData(gl.ARRAY_BUFFER, 0, size, ptr)
+		}
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	if b.Layout.Stride > 0 {
+		b.Count = int32(size / b.Layout.Stride)
+	}
+}
+
+// Attach adds the instance attributes to a VAO.
+func (b *InstanceBuffer) Attach(vao uint32) {
+	gl.BindVertexArray(vao)
+	b.attach()
+	gl.BindVertexArray(0)
+}
+
+// attach adds the instance attributes to the bound VAO.
+func (b *InstanceBuffer) attach() {
+	gl.BindBuffer(gl.ARRAY_BUFFER, b.Vbo)
+	b.Layout.apply()
+	for _, a := range b.Layout.Attributes {
+		gl.VertexAttribDivisor(a.Location, b.Divisor)
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+}
+
+func (b *InstanceBuffer) Delete() {
+	gl.DeleteBuffers(1, &b.Vbo)
+}
+
+// instanceCount returns the number of instances the buffers have data for,
+// 0 when there are none.
+func instanceCount(buffers []*InstanceBuffer) int32 {
+	n := int32(-1)
+	for _, b := range buffers {
+		if b.Divisor == 0 {
+			continue
+		}
+		if c := b.Count * int32(b.Divisor); n < 0 || c < n {
+			n = c
+		}
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
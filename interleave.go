@@ -0,0 +1,138 @@
+package glutils
+
+import (
+	"fmt"
+	"sort"
+)
+
+// VertexStream is the data of a single vertex attribute, Size floats per
+// vertex, bound to the shader input at Location.
+type VertexStream struct {
+	Name     string
+	Location uint32
+	Size     int
+	Data     []float32
+}
+
+// Stream returns a stream for the vertex input name of the program. ok is
+// false when the input is not active.
+func (s *Shader) Stream(name string, size int, data []float32) (VertexStream, bool) {
+	loc, ok := s.Attributes[name]
+	return VertexStream{Name: name, Location: loc, Size: size, Data: data}, ok
+}
+
+// Count returns the number of vertices in the stream.
+func (vs VertexStream) Count() int {
+	if vs.Size == 0 {
+		return 0
+	}
+	return len(vs.Data) / vs.Size
+}
+
+// Interleave packs streams into a single buffer, the attributes of a vertex
+// following each other in the order of the streams. It returns the buffer with
+// the matching attributes and stride in floats, ready for a VertexArray.
+func Interleave(streams ...VertexStream) ([]float32, AttributesMap, int32, error) {
+	attributes := NewAttributesMap()
+	count, stride := 0, 0
+	for i, s := range streams {
+		if s.Size < 1 || s.Size > 4 {
+			return nil, nil, 0, fmt.Errorf("stream %q: size must be 1 to 4, got %d", s.Name, s.Size)
+		}
+		if len(s.Data)%s.Size != 0 {
+			return nil, nil, 0, fmt.Errorf("stream %q: %d floats is not a multiple of the size %d", s.Name, len(s.Data), s.Size)
+		}
+		if i == 0 {
+			count = s.Count()
+		} else if s.Count() != count {
+			return nil, nil, 0, fmt.Errorf("stream %q has %d vertices, stream %q has %d", s.Name, s.Count(), streams[0].Name, count)
+		}
+		if _, ok := attributes[s.Location]; ok {
+			return nil, nil, 0, fmt.Errorf("stream %q: location %d is used twice", s.Name, s.Location)
+		}
+		attributes.Add(s.Location, s.Size, stride)
+		stride += s.Size
+	}
+
+	data := make([]float32, count*stride)
+	offset := 0
+	for _, s := range streams {
+		for v := 0; v < count; v++ {
+			copy(data[v*stride+offset:], s.Data[v*s.Size:(v+1)*s.Size])
+		}
+		offset += s.Size
+	}
+	return data, attributes, int32(stride), nil
+}
+
+// NewInterleavedVertexArray interleaves streams into the Data of a new
+// VertexArray, to be set up.
+func NewInterleavedVertexArray(streams ...VertexStream) (*VertexArray, error) {
+	data, attributes, stride, err := Interleave(streams...)
+	if err != nil {
+		return nil, err
+	}
+	return &VertexArray{
+		Data:       data,
+		Stride:     stride,
+		Attributes: attributes,
+	}, nil
+}
+
+// Deinterleave splits an interleaved buffer into one stream per attribute,
+// sorted by offset, then location for attributes at the same offset. stride
+// and the attributes are in floats.
+func Deinterleave(data []float32, stride int32, attributes AttributesMap) ([]VertexStream, error) {
+	if stride <= 0 {
+		return nil, fmt.Errorf("deinterleave: invalid stride %d", stride)
+	}
+	if len(data)%int(stride) != 0 {
+		return nil, fmt.Errorf("deinterleave: %d floats is not a multiple of the stride %d", len(data), stride)
+	}
+	count := len(data) / int(stride)
+
+	streams := make([]VertexStream, 0, len(attributes))
+	for loc, ss := range attributes {
+		size, offset := ss[0], ss[1]
+		if size < 1 || size > 4 {
+			return nil, fmt.Errorf("deinterleave: attribute %d: size must be 1 to 4, got %d", loc, size)
+		}
+		if offset < 0 {
+			return nil, fmt.Errorf("deinterleave: attribute %d: negative offset %d", loc, offset)
+		}
+		if offset+size > int(stride) {
+			return nil, fmt.Errorf("deinterleave: attribute %d ends at %d past the stride %d", loc, offset+size, stride)
+		}
+		s := VertexStream{Location: loc, Size: size, Data: make([]float32, count*size)}
+		for v := 0; v < count; v++ {
+			start := v*int(stride) + offset
+			copy(s.Data[v*size:], data[start:start+size])
+		}
+		streams = append(streams, s)
+	}
+	sort.SliceStable(streams, func(i, j int) bool {
+		a, b := attributes[streams[i].Location][1], attributes[streams[j].Location][1]
+		if a != b {
+			return a < b
+		}
+		return streams[i].Location < streams[j].Location
+	})
+	return streams, nil
+}
+
+// Streams splits Data into one stream per attribute, see Deinterleave.
+// Names are set from names, usually Shader.Attributes, when given.
+func (v *VertexArray) Streams(names map[string]uint32) ([]VertexStream, error) {
+	streams, err := Deinterleave(v.Data, v.Stride, v.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	for name, loc := range names {
+		for i := range streams {
+			if streams[i].Location == loc {
+				streams[i].Name = name
+			}
+		}
+	}
+	return streams, nil
+}
@@ -0,0 +1,107 @@
+package glutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInterleave(t *testing.T) {
+	data, attributes, stride, err := Interleave(
+		VertexStream{Name: "position", Location: 0, Size: 3, Data: []float32{0, 0, 0, 1, 1, 1}},
+		VertexStream{Name: "uv", Location: 2, Size: 2, Data: []float32{0, 1, 1, 0}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float32{0, 0, 0, 0, 1, 1, 1, 1, 1, 0}; !reflect.DeepEqual(data, want) {
+		t.Errorf("data %v, want %v", data, want)
+	}
+	if want := (AttributesMap{0: {3, 0}, 2: {2, 3}}); !reflect.DeepEqual(attributes, want) {
+		t.Errorf("attributes %v, want %v", attributes, want)
+	}
+	if stride != 5 {
+		t.Errorf("stride %d, want 5", stride)
+	}
+}
+
+func TestInterleaveErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		streams []VertexStream
+	}{
+		{"size", []VertexStream{{Size: 5, Data: make([]float32, 5)}}},
+		{"partial vertex", []VertexStream{{Size: 3, Data: make([]float32, 4)}}},
+		{"counts", []VertexStream{{Size: 1, Data: make([]float32, 2)}, {Location: 1, Size: 1, Data: make([]float32, 3)}}},
+		{"location", []VertexStream{{Size: 1, Data: make([]float32, 2)}, {Size: 1, Data: make([]float32, 2)}}},
+	}
+	for _, tt := range tests {
+		if _, _, _, err := Interleave(tt.streams...); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+func TestDeinterleave(t *testing.T) {
+	data := []float32{
+		0, 1, 2, 3,
+		4, 5, 6, 7,
+	}
+	tests := []struct {
+		name       string
+		attributes AttributesMap
+		want       []VertexStream
+	}{
+		{
+			name:       "by offset",
+			attributes: AttributesMap{3: {1, 3}, 1: {3, 0}},
+			want: []VertexStream{
+				{Location: 1, Size: 3, Data: []float32{0, 1, 2, 4, 5, 6}},
+				{Location: 3, Size: 1, Data: []float32{3, 7}},
+			},
+		},
+		{
+			name:       "aliased offsets by location",
+			attributes: AttributesMap{5: {2, 0}, 2: {4, 0}, 4: {1, 0}},
+			want: []VertexStream{
+				{Location: 2, Size: 4, Data: []float32{0, 1, 2, 3, 4, 5, 6, 7}},
+				{Location: 4, Size: 1, Data: []float32{0, 4}},
+				{Location: 5, Size: 2, Data: []float32{0, 1, 4, 5}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		// map iteration order varies, the result must not
+		for i := 0; i < 10; i++ {
+			got, err := Deinterleave(data, 4, tt.attributes)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestDeinterleaveErrors(t *testing.T) {
+	data := make([]float32, 8)
+	tests := []struct {
+		name       string
+		stride     int32
+		attributes AttributesMap
+	}{
+		{"zero stride", 0, AttributesMap{0: {1, 0}}},
+		{"partial vertex", 3, AttributesMap{0: {1, 0}}},
+		{"past the stride", 4, AttributesMap{0: {2, 3}}},
+		{"negative offset", 4, AttributesMap{0: {2, -1}}},
+		{"negative size", 4, AttributesMap{0: {-2, 2}}},
+		{"zero size", 4, AttributesMap{0: {0, 0}}},
+		{"size over 4", 8, AttributesMap{0: {5, 0}}},
+	}
+	for _, tt := range tests {
+		if _, err := Deinterleave(data, tt.stride, tt.attributes); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
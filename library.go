@@ -0,0 +1,79 @@
+package glutils
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ShaderLibrary caches shader permutations. Requesting the same files with the
+// same define set returns the Shader compiled the first time.
+type ShaderLibrary struct {
+	mu      sync.Mutex
+	shaders map[string]*Shader
+}
+
+func NewShaderLibrary() *ShaderLibrary {
+	return &ShaderLibrary{shaders: make(map[string]*Shader)}
+}
+
+// Get returns the shader built from the files with defines, compiling it on
+// first use. It must be called on the thread owning the GL context.
+func (l *ShaderLibrary) Get(vertFile, fragFile, geomFile string, defines map[string]string) (*Shader, error) {
+	key := permutationKey(defines, vertFile, fragFile, geomFile)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s, ok := l.shaders[key]; ok {
+		return s, nil
+	}
+	// copy the defines so later changes by the caller don't alter the key
+	defs := make(map[string]string, len(defines))
+	for k, v := range defines {
+		defs[k] = v
+	}
+	shader, err := NewShaderWithDefines(vertFile, fragFile, geomFile, defs)
+	if err != nil {
+		return nil, err
+	}
+	l.shaders[key] = &shader
+	return &shader, nil
+}
+
+// Len returns the number of compiled permutations.
+func (l *ShaderLibrary) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.shaders)
+}
+
+// Delete deletes every program in the library.
+func (l *ShaderLibrary) Delete() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, s := range l.shaders {
+		s.Delete()
+		delete(l.shaders, k)
+	}
+}
+
+func permutationKey(defines map[string]string, files ...string) string {
+	names := make([]string, 0, len(defines))
+	for name := range defines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, f := range files {
+		b.WriteString(f)
+		b.WriteByte(0)
+	}
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(defines[name])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
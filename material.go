@@ -0,0 +1,126 @@
+package glutils
+
+import (
+	"math"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Material is the constant parameters of a mesh surface, modulated by its
+// texture maps when it has some.
+type Material struct {
+	Name      string
+	Ambient   mgl32.Vec3
+	Diffuse   mgl32.Vec3
+	Specular  mgl32.Vec3
+	Emissive  mgl32.Vec3
+	Shininess float32
+	Opacity   float32
+	TwoSided  bool
+}
+
+// DefaultMaterial is the material of meshes without one: white, opaque and
+// slightly shiny.
+var DefaultMaterial = Material{
+	Diffuse:   mgl32.Vec3{1, 1, 1},
+	Specular:  mgl32.Vec3{0.5, 0.5, 0.5},
+	Shininess: 32,
+	Opacity:   1,
+}
+
+// NewDefaultMaterial returns a copy of DefaultMaterial.
+func NewDefaultMaterial() *Material {
+	m := DefaultMaterial
+	return &m
+}
+
+// Material returns the constant parameters of the MTL material.
+func (mt OBJMaterial) Material() *Material {
+	return &Material{
+		Name:      mt.Name,
+		Ambient:   mt.Ambient,
+		Diffuse:   mt.Diffuse,
+		Specular:  mt.Specular,
+		Emissive:  mt.Emissive,
+		Shininess: mt.Shininess,
+		Opacity:   mt.Opacity,
+	}
+}
+
+// Material approximates the PBR material for Phong shading: metals reflect
+// their base color, and the shininess decreases with the roughness.
+func (mt GLTFMaterial) Material() *Material {
+	base := mt.BaseColorFactor.Vec3()
+	f0 := mgl32.Vec3{0.04, 0.04, 0.04}
+	r := mt.RoughnessFactor
+	if r < 0.01 {
+		r = 0.01
+	}
+	return &Material{
+		Name:      mt.Name,
+		Diffuse:   base.Mul(1 - mt.MetallicFactor),
+		Specular:  f0.Add(base.Sub(f0).Mul(mt.MetallicFactor)),
+		Emissive:  mt.EmissiveFactor,
+		Shininess: shininess(r),
+		Opacity:   mt.BaseColorFactor[3],
+		TwoSided:  mt.DoubleSided,
+	}
+}
+
+// shininess converts a roughness to a Phong exponent, the inverse of roughness.
+func shininess(roughness float32) float32 {
+	n := 2/math.Pow(float64(roughness), 4) - 2
+	return float32(math.Max(1, math.Min(n, 1024)))
+}
+
+// roughness converts a Phong exponent to a roughness.
+func roughness(shininess float32) float32 {
+	return float32(math.Pow(2/(math.Max(float64(shininess), 0)+2), 0.25))
+}
+
+// materialLocations is the locations of the fields of a material struct
+// uniform, looked up once per program.
+type materialLocations struct {
+	ambient, diffuse, specular, emissive, shininess, opacity int32
+}
+
+// materialUniformLocations returns the locations of the fields of the
+// material struct uniform name, the name.ambient, name.diffuse,
+// name.specular and name.emissive vec3, name.shininess and name.opacity
+// floats.
+func materialUniformLocations(program uint32, name string) *materialLocations {
+	loc := func(field string) int32 {
+		return gl.GetUniformLocation(program, gl.Str(name+"."+field+"\x00"))
+	}
+	return &materialLocations{
+		ambient:   loc("ambient"),
+		diffuse:   loc("diffuse"),
+		specular:  loc("specular"),
+		emissive:  loc("emissive"),
+		shininess: loc("shininess"),
+		opacity:   loc("opacity"),
+	}
+}
+
+// bindMaterial sets the uniforms at locs to m, and leaves them to the caller
+// when locs is nil. Culling, when cull tells it is enabled, is disabled for
+// two-sided materials, the returned function restores it.
+func bindMaterial(locs *materialLocations, m *Material, cull bool) func() {
+	if m == nil {
+		m = &DefaultMaterial
+	}
+	if locs != nil {
+		gl.Uniform3fv(locs.ambient, 1, &m.Ambient[0])
+		gl.Uniform3fv(locs.diffuse, 1, &m.Diffuse[0])
+		gl.Uniform3fv(locs.specular, 1, &m.Specular[0])
+		gl.Uniform3fv(locs.emissive, 1, &m.Emissive[0])
+		gl.Uniform1f(locs.shininess, m.Shininess)
+		gl.Uniform1f(locs.opacity, m.Opacity)
+	}
+	if m.TwoSided && cull {
+		gl.Disable(gl.CULL_FACE)
+		return func() { gl.Enable(gl.CULL_FACE) }
+	}
+	return func() {}
+}
@@ -1,12 +1,14 @@
 package glutils
 
-import "C"
-
 import (
+	"bytes"
 	"encoding/gob"
-	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -14,16 +16,27 @@ import (
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
-	"github.com/raedatoui/assimp"
 )
 
 type Mesh struct {
 	Id       int
 	Vertices []Vertex
 	Indices  []uint32
-	Textures []Texture
-	vao      uint32
-	vbo, ebo uint32
+	// IndexType is the type of the uploaded indices, see IndexType.
+	IndexType uint32
+	Textures  []Texture
+	// Material is the constant parameters of the surface, DefaultMaterial
+	// when nil.
+	Material *Material
+	// Points draws the vertices listed by Indices as gl.POINTS instead of
+	// triangles, for point clouds.
+	Points bool
+	// skinned is set when vertices have bone weights.
+	skinned   bool
+	vao       uint32
+	vbo, ebo  uint32
+	pool      *GeometryPool
+	poolRange GeometryRange
 }
 
 func NewMesh(v []Vertex, i []uint32, t []Texture) Mesh {
@@ -36,12 +49,10 @@ func NewMesh(v []Vertex, i []uint32, t []Texture) Mesh {
 	return m
 }
 
-func (m *Mesh) setup() {
-	// size of the Vertex struct
-	dummy := m.Vertices[0]
-	structSize := int(unsafe.Sizeof(dummy))
-	structSize32 := int32(structSize)
+// vertexLayout is the layout of Vertex, from the locations in its tags.
+var vertexLayout = mustVertexLayoutOf(Vertex{})
 
+func (m *Mesh) setup() {
 	// Create buffers/arrays
 	gl.GenVertexArrays(1, &m.vao)
 	gl.GenBuffers(1, &m.vbo)
@@ -53,35 +64,75 @@ func (m *Mesh) setup() {
 	// A great thing about structs is that their memory layout is sequential for all its items.
 	// The effect is that we can simply pass a pointer to the struct and it translates perfectly to a gl.m::vec3/2 array which
 	// again translates to 3/2 floats which translates to a byte array.
-	gl.BufferData(gl.ARRAY_BUFFER, len(m.Vertices)*structSize, gl.Ptr(m.Vertices), gl.STATIC_DRAW)
+	gl.BufferData(gl.ARRAY_BUFFER, len(m.Vertices)*vertexLayout.Stride, gl.Ptr(m.Vertices), gl.STATIC_DRAW)
 
+	// gob files written before the index type was recorded leave it at 0
+	if m.IndexType == 0 {
+		m.IndexType = IndexType(m.Indices)
+	}
+	indexSize, indexPtr := packIndices(m.Indices, m.IndexType)
 	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, m.ebo)
-	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(m.Indices)*GL_FLOAT32_SIZE, gl.Ptr(m.Indices), gl.STATIC_DRAW)
+	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, indexSize, indexPtr, gl.STATIC_DRAW)
 
 	// Set the vertex attribute pointers
-	// Vertex Positions
-	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, structSize32, gl.PtrOffset(0))
-	gl.EnableVertexAttribArray(0)
+	vertexLayout.apply()
 
-	// Vertex Normals
-	gl.VertexAttribPointer(1, 3, gl.FLOAT, false, structSize32, unsafe.Pointer((unsafe.Offsetof(dummy.Normal))))
-	gl.EnableVertexAttribArray(1)
+	gl.BindVertexArray(0)
+	m.skinned = hasBoneWeights(m.Vertices)
+}
 
-	// Vertex Texture Coords
-	gl.VertexAttribPointer(2, 2, gl.FLOAT, false, structSize32, unsafe.Pointer((unsafe.Offsetof(dummy.TexCoords))))
-	gl.EnableVertexAttribArray(2)
+// hasBoneWeights reports whether a vertex is attached to a bone.
+func hasBoneWeights(vertices []Vertex) bool {
+	for i := range vertices {
+		if vertices[i].Weights != [MaxBoneInfluences]float32{} {
+			return true
+		}
+	}
+	return false
+}
 
-	// Vertex Tangent
-	gl.EnableVertexAttribArray(3)
-	gl.VertexAttribPointer(3, 3, gl.FLOAT, false, structSize32, unsafe.Pointer(unsafe.Offsetof(dummy.Tangent)))
-	// Vertex Bitangent
-	gl.EnableVertexAttribArray(4)
-	gl.VertexAttribPointer(4, 3, gl.FLOAT, false, structSize32, unsafe.Pointer(unsafe.Offsetof(dummy.Bitangent)))
+// placement returns the model matrix of the mesh drawn by a node at world in
+// a model placed by transform. The bone palette already places skinned
+// vertices in model space, their node is ignored as glTF requires.
+func (m *Mesh) placement(transform, world mgl32.Mat4) mgl32.Mat4 {
+	if m.skinned {
+		return transform
+	}
+	return world
+}
 
-	gl.BindVertexArray(0)
+// draw draws the mesh, instances times when instances is not 0, with its
+// material set to the material uniform struct at locs.
+func (m *Mesh) draw(program uint32, locs *modelLocations, instances int32) {
+	restore := bindMaterial(locs.material, m.Material, locs.cull)
+	defer restore()
+	bindTextures(program, m.Textures)
+
+	// Draw mesh
+	switch {
+	case m.pool != nil:
+		m.pool.Draw(m.poolRange)
+	default:
+		mode := uint32(gl.TRIANGLES)
+		if m.Points {
+			mode = gl.POINTS
+		}
+		gl.BindVertexArray(m.vao)
+		if instances > 0 {
+			gl.DrawElementsInstanced(mode, int32(len(m.Indices)), m.IndexType, gl.PtrOffset(0), instances)
+		} else {
+			gl.DrawElements(mode, int32(len(m.Indices)), m.IndexType, gl.PtrOffset(0))
+		}
+		gl.BindVertexArray(0)
+	}
+
+	unbindTextures(m.Textures)
 }
 
-func (m *Mesh) draw(program uint32) {
+// bindTextures binds textures to consecutive texture units and sets the
+// matching sampler uniforms, texture_diffuse1, texture_diffuse2,
+// texture_specular1...
+func bindTextures(program uint32, textures []Texture) {
 	// Bind appropriate textures
 	var (
 		diffuseNr  uint64
@@ -95,12 +146,12 @@ func (m *Mesh) draw(program uint32) {
 	normalNr = 1
 	heightNr = 1
 	i = 0
-	for i = 0; i < uint32(len(m.Textures)); i++ {
+	for i = 0; i < uint32(len(textures)); i++ {
 		gl.ActiveTexture(gl.TEXTURE0 + i) // Active proper texture unit before binding
 
 		// Retrieve texture number (the N in diffuse_textureN)
 		ss := ""
-		switch m.Textures[i].TextureType {
+		switch textures[i].TextureType {
 		case "texture_diffuse":
 			ss = ss + strconv.FormatUint(diffuseNr, 10) // Transfer GLuint to stream
 			diffuseNr++
@@ -116,43 +167,67 @@ func (m *Mesh) draw(program uint32) {
 		}
 
 		// Now set the sampler to the correct texture unit
-		tu := m.Textures[i].TextureType + ss + "\x00"
+		tu := textures[i].TextureType + ss + "\x00"
 
 		gl.Uniform1i(gl.GetUniformLocation(program, gl.Str(tu)), int32(i))
 		// And finally bind the texture
-		gl.BindTexture(gl.TEXTURE_2D, m.Textures[i].id)
+		gl.BindTexture(gl.TEXTURE_2D, textures[i].id)
 	}
+}
 
-	// Draw mesh
-	gl.BindVertexArray(m.vao)
-	gl.DrawElements(gl.TRIANGLES, int32(len(m.Indices)), gl.UNSIGNED_INT, gl.PtrOffset(0))
-	gl.BindVertexArray(0)
-
+func unbindTextures(textures []Texture) {
 	// Always good practice to set everything back to defaults once configured.
-	for i = 0; i < uint32(len(m.Textures)); i++ {
+	for i := uint32(0); i < uint32(len(textures)); i++ {
 		gl.ActiveTexture(gl.TEXTURE0 + i)
 		gl.BindTexture(gl.TEXTURE_2D, 0)
 	}
 }
 
 type Vertex struct {
-	Position  mgl32.Vec3
-	Normal    mgl32.Vec3
-	TexCoords mgl32.Vec2
-	Tangent   mgl32.Vec3
-	Bitangent mgl32.Vec3
+	Position  mgl32.Vec3 `gl:"loc=0"`
+	Normal    mgl32.Vec3 `gl:"loc=1"`
+	TexCoords mgl32.Vec2 `gl:"loc=2"`
+	Tangent   mgl32.Vec3 `gl:"loc=3"`
+	Bitangent mgl32.Vec3 `gl:"loc=4"`
+	// BoneIDs are indices in the model skeleton, with the weights of their
+	// influence. Vertices of meshes without bones have zero weights.
+	BoneIDs [MaxBoneInfluences]int32   `gl:"loc=5"`
+	Weights [MaxBoneInfluences]float32 `gl:"loc=6"`
 }
 
 type Texture struct {
 	id          uint32
 	TextureType string
 	Path        string
+	// Data is the encoded image of textures embedded in the model file, Path
+	// then only identifies it.
+	Data []byte
 }
 
 type Model struct {
-	texturesLoaded  map[string]Texture
-	wg              sync.WaitGroup
-	Meshes          []Mesh
+	texturesLoaded map[string]Texture
+	wg             sync.WaitGroup
+	fsys           fs.FS
+	instances      []*InstanceBuffer
+	pool           *GeometryPool
+	batches        []textureBatch
+	locations      map[uint32]*modelLocations
+	locationsGen   uint64
+	Meshes         []Mesh
+	// Root is the scene graph of the model, placing its meshes.
+	Root *Node
+	// ModelUniform is the mat4 uniform Draw sets to the world matrix of each
+	// node, "model" by default. Skinned meshes get the placement of the model
+	// only, the bone palette places them. When empty the uniform is left to
+	// the caller.
+	ModelUniform string
+	// MaterialUniform is the struct uniform Draw sets to the material of each
+	// mesh, "material" by default, see Material. When empty the uniforms are
+	// left to the caller.
+	MaterialUniform string
+	// Skeleton is the bones of skinned meshes, nil when there are none.
+	Skeleton        *Skeleton
+	Animations      []AnimationClip
 	GammaCorrection bool
 	BasePath        string
 	FileName        string
@@ -160,6 +235,17 @@ type Model struct {
 }
 
 func NewModel(b, f string, g bool) (Model, error) {
+	return newModel(nil, b, f, g)
+}
+
+// NewModelFS is NewModel reading the model, its gob cache and its textures
+// from fsys. Since fsys is read-only the gob cache is used when present but
+// never written.
+func NewModelFS(fsys fs.FS, b, f string, g bool) (Model, error) {
+	return newModel(fsys, b, f, g)
+}
+
+func newModel(fsys fs.FS, b, f string, g bool) (Model, error) {
 	t := strings.Split(f, ".")
 	gf := t[0] + ".gob"
 	m := Model{
@@ -167,10 +253,18 @@ func NewModel(b, f string, g bool) (Model, error) {
 		FileName:        f,
 		GobName:         gf,
 		GammaCorrection: g,
+		ModelUniform:    "model",
+		MaterialUniform: "material",
+		fsys:            fsys,
 	}
 	m.texturesLoaded = make(map[string]Texture)
 	gobFile := b + gf
-	if _, err := os.Stat(gobFile); os.IsNotExist(err) {
+	if fsys != nil {
+		if _, err := fs.Stat(fsys, gobFile); err != nil {
+			err := m.loadModel()
+			return m, err
+		}
+	} else if _, err := os.Stat(gobFile); os.IsNotExist(err) {
 		err := m.loadModel()
 		m.Export()
 		return m, err
@@ -179,10 +273,229 @@ func NewModel(b, f string, g bool) (Model, error) {
 	return m, err
 }
 
+// Draw draws the meshes of every node with the node world matrix set to
+// ModelUniform. With instance buffers set, it draws one instance per element
+// of the shortest buffer, and nothing when one is empty.
+// The uniform locations are looked up once per program, programs must be
+// deleted with Shader.Delete for a reused program name to be looked up again.
 func (m *Model) Draw(shader uint32) {
+	m.DrawTransformed(shader, mgl32.Ident4())
+}
+
+// DrawTransformed is Draw with the root of the model placed by transform.
+func (m *Model) DrawTransformed(shader uint32, transform mgl32.Mat4) {
+	instances := instanceCount(m.instances)
+	if len(m.instances) > 0 && instances == 0 {
+		// the shader reads per-instance attributes, there is no instance to draw
+		return
+	}
+	m.draw(shader, transform, instances)
+}
+
+func (m *Model) draw(shader uint32, transform mgl32.Mat4, instances int32) {
+	locs := m.uniformLocations(shader)
+	locs.cull = gl.IsEnabled(gl.CULL_FACE)
+	if m.pool != nil {
+		for i := range m.batches {
+			b := &m.batches[i]
+			world := transform
+			if !b.skinned {
+				world = transform.Mul4(b.node.WorldMatrix())
+			}
+			locs.setModel(world)
+			restoreFace := frontFace(world)
+			restore := bindMaterial(locs.material, b.material, locs.cull)
+			bindTextures(shader, b.textures)
+			m.pool.DrawBatch(&b.batch)
+			unbindTextures(b.textures)
+			restore()
+			restoreFace()
+		}
+		return
+	}
+	m.root().Walk(transform, func(n *Node, world mgl32.Mat4) {
+		for _, i := range n.Meshes {
+			mesh := &m.Meshes[i]
+			place := mesh.placement(transform, world)
+			locs.setModel(place)
+			restoreFace := frontFace(place)
+			mesh.draw(shader, locs, instances)
+			restoreFace()
+		}
+	})
+}
+
+// frontFace makes clockwise triangles front facing when world mirrors them,
+// and returns a func restoring counter-clockwise front faces.
+func frontFace(world mgl32.Mat4) func() {
+	if world.Mat3().Det() >= 0 {
+		return func() {}
+	}
+	gl.FrontFace(gl.CW)
+	return func() { gl.FrontFace(gl.CCW) }
+}
+
+// modelLocations is the locations of the uniforms a program draws a model
+// with, cached by Model per program for the ModelUniform and MaterialUniform
+// names they were looked up with. The cache is dropped when Shader.Delete or
+// Shader.Reload deletes a program. material is nil when MaterialUniform is
+// empty.
+type modelLocations struct {
+	modelName, materialName string
+	model                   int32
+	material                *materialLocations
+	// cull is whether culling is enabled, queried once per draw.
+	cull bool
+}
+
+// uniformLocations returns the uniform locations of shader, looked up on its
+// first draw or when the uniform names change.
+func (m *Model) uniformLocations(shader uint32) *modelLocations {
+	if m.locationsGen != programGeneration {
+		// a program was deleted, its name may now be another program's
+		m.locations, m.locationsGen = nil, programGeneration
+	}
+	locs := m.locations[shader]
+	if locs != nil && locs.modelName == m.ModelUniform && locs.materialName == m.MaterialUniform {
+		return locs
+	}
+	locs = &modelLocations{modelName: m.ModelUniform, materialName: m.MaterialUniform, model: -1}
+	if m.ModelUniform != "" {
+		locs.model = gl.GetUniformLocation(shader, gl.Str(m.ModelUniform+"\x00"))
+	}
+	if m.MaterialUniform != "" {
+		locs.material = materialUniformLocations(shader, m.MaterialUniform)
+	}
+	if m.locations == nil {
+		m.locations = make(map[uint32]*modelLocations)
+	}
+	m.locations[shader] = locs
+	return locs
+}
+
+func (l *modelLocations) setModel(world mgl32.Mat4) {
+	if l.modelName == "" {
+		return
+	}
+	gl.UniformMatrix4fv(l.model, 1, false, &world[0])
+}
+
+// root returns the scene graph, a single node drawing every mesh for models
+// built without one.
+func (m *Model) root() *Node {
+	if m.Root == nil {
+		m.Root = flatNode(m.FileName, len(m.Meshes))
+	}
+	return m.Root
+}
+
+// FindNode returns the first node named name, nil if there is none.
+func (m *Model) FindNode(name string) *Node {
+	return m.root().Find(name)
+}
+
+// textureBatch is the meshes of a node of a pooled model using the same
+// textures and material.
+type textureBatch struct {
+	node     *Node
+	material *Material
+	textures []Texture
+	skinned  bool
+	batch    GeometryBatch
+}
+
+// UsePool moves the meshes of the model to a pool created with the Vertex
+// layout, see NewModelGeometryPool, and releases their own buffers. Draw then
+// draws the meshes of a node sharing the same material with a single call.
+// The pool is shared and not deleted by Dispose, so pooled models can't have
+// instance buffers.
+// Its primitive must be gl.POINTS for point cloud meshes, triangles otherwise.
+func (m *Model) UsePool(pool *GeometryPool) error {
+	if len(m.instances) > 0 {
+		return fmt.Errorf("model has instance buffers, they would be attached to the shared pool")
+	}
+	if pool.Layout.Stride != vertexLayout.Stride {
+		return fmt.Errorf("geometry pool stride is %d, model vertices are %d bytes", pool.Layout.Stride, vertexLayout.Stride)
+	}
+	for i := range m.Meshes {
+		if m.Meshes[i].Points != (pool.primitive() == gl.POINTS) {
+			return fmt.Errorf("mesh %d and the geometry pool draw different primitives", i)
+		}
+	}
+	for i := range m.Meshes {
+		mesh := &m.Meshes[i]
+		var ptr unsafe.Pointer
+		if len(mesh.Vertices) > 0 {
+			ptr = gl.Ptr(mesh.Vertices)
+		}
+		r, err := pool.add(len(mesh.Vertices), ptr, mesh.Indices)
+		if err != nil {
+			return err
+		}
+		mesh.release()
+		mesh.pool, mesh.poolRange = pool, r
+	}
+	// materials are compared by value, gob files don't keep them shared
+	type batchKey struct {
+		material Material
+		textures string
+		skinned  bool
+	}
+	m.root().Walk(mgl32.Ident4(), func(n *Node, _ mgl32.Mat4) {
+		batches := make(map[batchKey]int)
+		for _, i := range n.Meshes {
+			mesh := &m.Meshes[i]
+			key := batchKey{material: DefaultMaterial, skinned: mesh.skinned}
+			if mesh.Material != nil {
+				key.material = *mesh.Material
+			}
+			var textures strings.Builder
+			for _, t := range mesh.Textures {
+				fmt.Fprintf(&textures, "%s:%d,", t.TextureType, t.id)
+			}
+			key.textures = textures.String()
+			b, ok := batches[key]
+			if !ok {
+				b = len(m.batches)
+				batches[key] = b
+				m.batches = append(m.batches, textureBatch{node: n, material: mesh.Material, textures: mesh.Textures, skinned: mesh.skinned})
+			}
+			m.batches[b].batch.Add(mesh.poolRange)
+		}
+	})
+	m.pool = pool
+	return nil
+}
+
+// NewModelGeometryPool allocates a pool for the vertices of models.
+func NewModelGeometryPool(vertices, indices int) *GeometryPool {
+	return NewGeometryPool(vertexLayout, vertices, indices)
+}
+
+// SetInstances attaches per-instance attribute buffers to every mesh of the
+// model, after which Draw draws one instance per element of the shortest
+// buffer. The mesh vertices use locations 0 to 6, instance attributes must
+// use the following ones. Models drawn from a GeometryPool share its VAO and
+// can't have instance buffers.
+func (m *Model) SetInstances(buffers ...*InstanceBuffer) error {
+	if m.pool != nil {
+		return fmt.Errorf("model draws from a shared geometry pool, instance buffers would apply to every model in it")
+	}
+	m.instances = append(m.instances, buffers...)
 	for i := 0; i < len(m.Meshes); i++ {
-		m.Meshes[i].draw(shader)
+		for _, b := range buffers {
+			b.Attach(m.Meshes[i].vao)
+		}
 	}
+	return nil
+}
+
+// DrawInstanced draws every mesh instances times, nothing when instances is 0.
+func (m *Model) DrawInstanced(shader uint32, instances int32) {
+	if instances <= 0 {
+		return
+	}
+	m.draw(shader, mgl32.Ident4(), instances)
 }
 
 func (m *Model) Export() error {
@@ -203,8 +516,7 @@ func (m *Model) Export() error {
 
 func (m *Model) Import() error {
 	f := m.BasePath + m.GobName
-	dataFile, err := os.Open(f)
-
+	dataFile, err := m.open(f)
 	if err != nil {
 		return err
 	}
@@ -216,217 +528,188 @@ func (m *Model) Import() error {
 	}
 
 	fmt.Printf("Creating model from gob file: %s\n", f)
+	if m.Root != nil {
+		m.Root.link()
+	}
 	m.initGL()
 	return nil
 }
 
 func (m *Model) Dispose() {
 	for i := 0; i < len(m.Meshes); i++ {
-		gl.DeleteVertexArrays(1, &m.Meshes[i].vao)
-		gl.DeleteBuffers(1, &m.Meshes[i].vbo)
-		gl.DeleteBuffers(1, &m.Meshes[i].ebo)
+		m.Meshes[i].release()
 	}
 }
 
-// Loads a model with supported ASSIMP extensions from file and stores the resulting meshes in the meshes vector.
-func (m *Model) loadModel() error {
-	// Read file via ASSIMP
-	path := m.BasePath + m.FileName
-	scene := assimp.ImportFile(path, uint(
-		assimp.Process_Triangulate|assimp.Process_FlipUVs))
-
-	// Check for errors
-	if scene.Flags()&assimp.SceneFlags_Incomplete != 0 { // if is Not Zero
-		fmt.Println("ERROR::ASSIMP:: %s\n", scene.Flags())
-		return errors.New("shit failed")
+// release deletes the buffers of the mesh, the pool owns pooled meshes data.
+func (m *Mesh) release() {
+	if m.vao == 0 {
+		return
 	}
+	gl.DeleteVertexArrays(1, &m.vao)
+	gl.DeleteBuffers(1, &m.vbo)
+	gl.DeleteBuffers(1, &m.ebo)
+	m.vao, m.vbo, m.ebo = 0, 0, 0
+}
 
-	// Process ASSIMP's root node recursively
-	m.processNode(scene.RootNode(), scene)
-	m.wg.Wait()
+// loadModel loads the model file and stores the resulting meshes. OBJ, glTF,
+// PLY and STL files are read natively, other formats with assimp.
+func (m *Model) loadModel() error {
+	var err error
+	switch strings.ToLower(path.Ext(m.FileName)) {
+	case ".obj":
+		err = m.loadOBJ()
+	case ".gltf", ".glb":
+		err = m.loadGLTF()
+	case ".ply":
+		err = m.loadPLY()
+	case ".stl":
+		err = m.loadSTL()
+	default:
+		err = m.loadAssimp()
+	}
+	if err != nil {
+		return err
+	}
 	m.initGL()
 	return nil
 }
 
-func (m *Model) initGL() {
-	// using a for loop with a range doesnt work here?!
-	// also making a temp var inside the loop doesnt work either?!
-	for i := 0; i < len(m.Meshes); i++ {
-		for j := 0; j < len(m.Meshes[i].Textures); j++ {
-			if val, ok := m.texturesLoaded[m.Meshes[i].Textures[j].Path]; ok {
-				m.Meshes[i].Textures[j].id = val.id
-			} else {
-				m.Meshes[i].Textures[j].id = m.textureFromFile(m.Meshes[i].Textures[j].Path)
-				m.texturesLoaded[m.Meshes[i].Textures[j].Path] = m.Meshes[i].Textures[j]
-			}
-		}
-		m.Meshes[i].setup()
+// open opens a file of the model directory, from fsys when set.
+func (m *Model) open(name string) (io.ReadCloser, error) {
+	if m.fsys != nil {
+		return m.fsys.Open(name)
 	}
+	return os.Open(name)
 }
 
-func (m *Model) processNode(n *assimp.Node, s *assimp.Scene) {
-	// Process each mesh located at the current node
-	m.wg.Add(n.NumMeshes() + n.NumChildren())
-
-	for i := 0; i < n.NumMeshes(); i++ {
-		// The node object only contains indices to index the actual objects in the scene.
-		// The scene contains all the data, node is just to keep stuff organized (like relations between nodes).
-		go func(index int) {
-			defer m.wg.Done()
-			mesh := s.Meshes()[n.Meshes()[index]]
-			ms := m.processMesh(mesh, s)
-			m.Meshes = append(m.Meshes, ms)
-		}(i)
-
+func (m *Model) loadOBJ() error {
+	f, err := m.open(m.BasePath + m.FileName)
+	if err != nil {
+		return err
 	}
-
-	// After we've processed all of the meshes (if any) we then recursively process each of the children nodes
-	c := n.Children()
-	for j := 0; j < len(c); j++ {
-		go func(n *assimp.Node, s *assimp.Scene) {
-			defer m.wg.Done()
-			m.processNode(n, s)
-		}(c[j], s)
+	defer f.Close()
+	meshes, err := ParseOBJ(f, func(name string) (io.ReadCloser, error) {
+		return m.open(m.BasePath + name)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %v", m.FileName, err)
+	}
+	for i := range meshes {
+		for j := range meshes[i].Textures {
+			meshes[i].Textures[j].Path = m.BasePath + meshes[i].Textures[j].Path
+		}
 	}
+	m.Meshes = meshes
+	return nil
 }
 
-func (m *Model) processMeshVertices(mesh *assimp.Mesh) []Vertex {
-	// Walk through each of the mesh's vertices
-	vertices := []Vertex{}
-
-	positions := mesh.Vertices()
-
-	normals := mesh.Normals()
-	useNormals := len(normals) > 0
-
-	tex := mesh.TextureCoords(0)
-	useTex := true
-	if tex == nil {
-		useTex = false
+func (m *Model) loadGLTF() error {
+	f, err := m.open(m.BasePath + m.FileName)
+	if err != nil {
+		return err
 	}
-
-	tangents := mesh.Tangents()
-	useTangents := len(tangents) > 0
-
-	bitangents := mesh.Bitangents()
-	useBitTangents := len(bitangents) > 0
-
-	for i := 0; i < mesh.NumVertices(); i++ {
-		// We declare a placeholder vector since assimp uses its own vector class that
-		// doesn't directly convert to glm's vec3 class so we transfer the data to this placeholder glm::vec3 first.
-		vertex := Vertex{}
-
-		// Positions
-		vertex.Position = mgl32.Vec3{positions[i].X(), positions[i].Y(), positions[i].Z()}
-
-		// Normals
-		if useNormals {
-			vertex.Normal = mgl32.Vec3{normals[i].X(), normals[i].Y(), normals[i].Z()}
-			//n.WriteString(fmt.Sprintf("[%f, %f, %f]\n", tmp[i].X(), tmp[i].Y(), tmp[i].Z()))
-		}
-
-		// Texture Coordinates
-		if useTex {
-			// Does the mesh contain texture coordinates?
-			// A vertex can contain up to 8 different texture coordinates. We thus make the assumption that we won't
-			// use models where a vertex can have multiple texture coordinates so we always take the first set (0).
-			vertex.TexCoords = mgl32.Vec2{tex[i].X(), tex[i].Y()}
-		} else {
-			vertex.TexCoords = mgl32.Vec2{0.0, 0.0}
-		}
-
-		// Tangent
-		if useTangents {
-			vertex.Tangent = mgl32.Vec3{tangents[i].X(), tangents[i].Y(), tangents[i].Z()}
+	data, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	g, err := ParseGLTF(data, func(uri string) ([]byte, error) {
+		f, err := m.open(m.BasePath + uri)
+		if err != nil {
+			return nil, err
 		}
-
-		// Bitangent
-		if useBitTangents {
-			vertex.Bitangent = mgl32.Vec3{bitangents[i].X(), bitangents[i].Y(), bitangents[i].Z()}
+		defer f.Close()
+		return ioutil.ReadAll(f)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %v", m.FileName, err)
+	}
+	for i := range g.Meshes {
+		for j := range g.Meshes[i].Textures {
+			t := &g.Meshes[i].Textures[j]
+			if t.Data != nil {
+				t.Path = m.BasePath + m.FileName + "#" + t.Path
+			} else {
+				t.Path = m.BasePath + t.Path
+			}
 		}
-
-		vertices = append(vertices, vertex)
 	}
-
-	return vertices
+	m.Meshes = g.Meshes
+	m.Root = g.Root
+	m.Root.Name = m.FileName
+	return nil
 }
 
-func (m *Model) processMeshIndices(mesh *assimp.Mesh) []uint32 {
-	indices := []uint32{}
-	// Now wak through each of the mesh's faces (a face is a mesh its triangle) and retrieve the corresponding vertex indices.
-	for i := 0; i < mesh.NumFaces(); i++ {
-		face := mesh.Faces()[i]
-		// Retrieve all indices of the face and store them in the indices vector
-		indices = append(indices, face.CopyIndices()...)
+func (m *Model) loadPLY() error {
+	f, err := m.open(m.BasePath + m.FileName)
+	if err != nil {
+		return err
 	}
-	return indices
+	defer f.Close()
+	p, err := ParsePLY(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", m.FileName, err)
+	}
+	m.Meshes = []Mesh{p.Mesh()}
+	return nil
 }
 
-func (m *Model) processMeshTextures(mesh *assimp.Mesh, s *assimp.Scene) []Texture {
-	textures := []Texture{}
-	// Process materials
-	if mesh.MaterialIndex() >= 0 {
-		material := s.Materials()[mesh.MaterialIndex()]
-
-		// We assume a convention for sampler names in the shaders. Each diffuse texture should be named
-		// as 'texture_diffuseN' where N is a sequential number ranging from 1 to MAX_SAMPLER_NUMBER.
-		// Same applies to other texture as the following list summarizes:
-		// Diffuse: texture_diffuseN
-		// Specular: texture_specularN
-		// Normal: texture_normalN
-
-		// 1. Diffuse maps
-		diffuseMaps := m.loadMaterialTextures(material, assimp.TextureMapping_Diffuse, "texture_diffuse")
-		textures = append(textures, diffuseMaps...)
-		// 2. Specular maps
-		specularMaps := m.loadMaterialTextures(material, assimp.TextureMapping_Specular, "texture_specular")
-		textures = append(textures, specularMaps...)
-		// 3. Normal maps
-		normalMaps := m.loadMaterialTextures(material, assimp.TextureMapping_Height, "texture_normal")
-		textures = append(textures, normalMaps...)
-		// 4. Height maps
-		heightMaps := m.loadMaterialTextures(material, assimp.TextureMapping_Ambient, "texture_height")
-		textures = append(textures, heightMaps...)
+func (m *Model) loadSTL() error {
+	f, err := m.open(m.BasePath + m.FileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	mesh, err := ParseSTL(f, DefaultSTLSmoothingAngle)
+	if err != nil {
+		return fmt.Errorf("%s: %v", m.FileName, err)
 	}
-	return textures
+	m.Meshes = []Mesh{mesh}
+	return nil
 }
 
-func (m *Model) processMesh(ms *assimp.Mesh, s *assimp.Scene) Mesh {
-	// Return a mesh object created from the extracted mesh data
-	return NewMesh(
-		m.processMeshVertices(ms),
-		m.processMeshIndices(ms),
-		m.processMeshTextures(ms, s))
+func (m *Model) initGL() {
+	// using a for loop with a range doesnt work here?!
+	// also making a temp var inside the loop doesnt work either?!
+	for i := 0; i < len(m.Meshes); i++ {
+		for j := 0; j < len(m.Meshes[i].Textures); j++ {
+			if val, ok := m.texturesLoaded[m.Meshes[i].Textures[j].Path]; ok {
+				m.Meshes[i].Textures[j].id = val.id
+			} else {
+				m.Meshes[i].Textures[j].id = m.loadTexture(m.Meshes[i].Textures[j])
+				m.texturesLoaded[m.Meshes[i].Textures[j].Path] = m.Meshes[i].Textures[j]
+			}
+		}
+		m.Meshes[i].setup()
+	}
 }
 
-func (m *Model) loadMaterialTextures(ms *assimp.Material, tm assimp.TextureMapping, tt string) []Texture {
-	textureType := assimp.TextureType(tm)
-	textureCount := ms.GetMaterialTextureCount(textureType)
-	result := []Texture{}
-
-	for i := 0; i < textureCount; i++ {
-		file, _, _, _, _, _, _, _ := ms.GetMaterialTexture(textureType, 0)
-		filename := m.BasePath + file
-		texture := Texture{id: 0, TextureType: tt, Path: filename}
-		result = append(result, texture)
-
-		//if val, ok := m.texturesLoaded[filename]; ok {
-		//	result = append(result, val)
-		//} else {
-		//	texId := m.textureFromFile(filename)
-		//	texture := Texture{id: texId, TextureType: tt, Path: filename}
-		//	result = append(result, texture)
-		//	m.texturesLoaded[filename] = texture
-		//}
+// loadTexture creates the GL texture of t, from its embedded data or its file.
+func (m *Model) loadTexture(t Texture) uint32 {
+	if t.Data == nil {
+		return m.textureFromFile(t.Path)
 	}
-	return result
+	rgba, err := decodePixelData(bytes.NewReader(t.Data))
+	if err != nil {
+		panic(fmt.Errorf("texture %q: %v", t.Path, err))
+	}
+	return newTexture(gl.REPEAT, gl.REPEAT, gl.LINEAR_MIPMAP_LINEAR, gl.LINEAR, rgba)
 }
 
 func (m *Model) textureFromFile(f string) uint32 {
 	//Generate texture ID and load texture data
-	if tex, err := NewTexture(gl.REPEAT, gl.REPEAT, gl.LINEAR_MIPMAP_LINEAR, gl.LINEAR, f); err != nil {
-		panic(err)
+	var (
+		tex uint32
+		err error
+	)
+	if m.fsys != nil {
+		tex, err = NewTextureFS(gl.REPEAT, gl.REPEAT, gl.LINEAR_MIPMAP_LINEAR, gl.LINEAR, m.fsys, f)
 	} else {
-		return tex
+		tex, err = NewTexture(gl.REPEAT, gl.REPEAT, gl.LINEAR_MIPMAP_LINEAR, gl.LINEAR, f)
+	}
+	if err != nil {
+		panic(err)
 	}
+	return tex
 }
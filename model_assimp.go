@@ -0,0 +1,343 @@
+//go:build !noassimp
+
+package glutils
+
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/raedatoui/assimp"
+)
+
+// Loads a model with supported ASSIMP extensions from file and stores the resulting meshes in the meshes vector.
+func (m *Model) loadAssimp() error {
+	// Read file via ASSIMP
+	path := m.BasePath + m.FileName
+	if m.fsys != nil {
+		// assimp can only read from disk
+		dir, err := extractDir(m.fsys, m.BasePath)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		path = filepath.Join(dir, m.FileName)
+	}
+	scene := assimp.ImportFile(path, uint(
+		assimp.Process_Triangulate|assimp.Process_FlipUVs))
+
+	// Check for errors
+	if scene.Flags()&assimp.SceneFlags_Incomplete != 0 { // if is Not Zero
+		fmt.Println("ERROR::ASSIMP:: %s\n", scene.Flags())
+		return errors.New("shit failed")
+	}
+
+	// Process the meshes of the scene in parallel, nodes refer to them by index
+	meshes := scene.Meshes()
+	m.Meshes = make([]Mesh, len(meshes))
+	m.wg.Add(len(meshes))
+	for i := range meshes {
+		go func(i int) {
+			defer m.wg.Done()
+			m.Meshes[i] = m.processMesh(meshes[i], scene)
+		}(i)
+	}
+	m.wg.Wait()
+
+	// Bones and materials are shared by the meshes, attach them one mesh at a time
+	materials := make([]*Material, len(scene.Materials()))
+	for i, mt := range scene.Materials() {
+		materials[i] = processMaterial(mt)
+	}
+	for i := range meshes {
+		m.processMeshBones(meshes[i], &m.Meshes[i])
+		if mi := meshes[i].MaterialIndex(); mi >= 0 && mi < len(materials) {
+			m.Meshes[i].Material = materials[mi]
+		}
+	}
+	for _, a := range scene.Animations() {
+		m.Animations = append(m.Animations, processAnimation(a))
+	}
+
+	// Process ASSIMP's root node recursively
+	m.Root = m.processNode(scene.RootNode())
+	return nil
+}
+
+// assimpMat4 converts a row major assimp matrix.
+func assimpMat4(a assimp.Matrix4x4) mgl32.Mat4 {
+	t := a.Values()
+	return mgl32.Mat4{
+		t[0][0], t[1][0], t[2][0], t[3][0],
+		t[0][1], t[1][1], t[2][1], t[3][1],
+		t[0][2], t[1][2], t[2][2], t[3][2],
+		t[0][3], t[1][3], t[2][3], t[3][3],
+	}
+}
+
+// processMeshBones adds the bones of the mesh to the skeleton of the model and
+// attaches the vertices to them, keeping the MaxBoneInfluences strongest.
+func (m *Model) processMeshBones(ms *assimp.Mesh, mesh *Mesh) {
+	bones := ms.Bones()
+	if len(bones) == 0 {
+		return
+	}
+	if m.Skeleton == nil {
+		m.Skeleton = &Skeleton{}
+	}
+	for _, b := range bones {
+		id := int32(m.Skeleton.AddBone(b.Name(), assimpMat4(b.OffsetMatrix())))
+		for _, w := range b.Weights() {
+			mesh.Vertices[w.VertexId()].AddBoneWeight(id, w.Weight())
+		}
+	}
+	for i := range mesh.Vertices {
+		mesh.Vertices[i].NormalizeWeights()
+	}
+}
+
+// processMaterial reads the constant parameters of a material, the keys it
+// lacks keep their DefaultMaterial value.
+func processMaterial(mt *assimp.Material) *Material {
+	material := NewDefaultMaterial()
+	if name, ret := mt.GetMaterialString("?mat.name", 0, 0); ret == assimp.Return_Success {
+		material.Name = name
+	}
+	for _, c := range []struct {
+		key   string
+		color *mgl32.Vec3
+	}{
+		{"$clr.ambient", &material.Ambient},
+		{"$clr.diffuse", &material.Diffuse},
+		{"$clr.specular", &material.Specular},
+		{"$clr.emissive", &material.Emissive},
+	} {
+		if v, ret := mt.GetMaterialColor(c.key, 0, 0); ret == assimp.Return_Success {
+			*c.color = mgl32.Vec3{v.R(), v.G(), v.B()}
+		}
+	}
+	if v, ret := mt.GetMaterialFloat("$mat.shininess", 0, 0); ret == assimp.Return_Success {
+		material.Shininess = v
+	}
+	if v, ret := mt.GetMaterialFloat("$mat.opacity", 0, 0); ret == assimp.Return_Success {
+		material.Opacity = v
+	}
+	if v, ret := mt.GetMaterialInteger("$mat.twosided", 0, 0); ret == assimp.Return_Success {
+		material.TwoSided = v != 0
+	}
+	return material
+}
+
+// processAnimation converts the node channels of an animation, with key times
+// in seconds.
+func processAnimation(a *assimp.Animation) AnimationClip {
+	tps := a.TicksPerSecond()
+	if tps == 0 {
+		tps = 25
+	}
+	clip := AnimationClip{Name: a.Name(), Duration: float32(a.Duration() / tps)}
+	for _, na := range a.NodeAnimationChannels() {
+		c := AnimationChannel{Node: na.NodeName()}
+		for _, k := range na.PositionKeys() {
+			v := k.Value()
+			c.Positions = append(c.Positions, VectorKey{float32(k.Time() / tps), mgl32.Vec3{v.X(), v.Y(), v.Z()}})
+		}
+		for _, k := range na.RotationKeys() {
+			q := k.Value()
+			c.Rotations = append(c.Rotations, QuatKey{float32(k.Time() / tps), mgl32.Quat{W: q.W(), V: mgl32.Vec3{q.X(), q.Y(), q.Z()}}})
+		}
+		for _, k := range na.ScalingKeys() {
+			v := k.Value()
+			c.Scales = append(c.Scales, VectorKey{float32(k.Time() / tps), mgl32.Vec3{v.X(), v.Y(), v.Z()}})
+		}
+		clip.Channels = append(clip.Channels, c)
+	}
+	return clip
+}
+
+// processNode returns the node tree of n, with its name and transform.
+func (m *Model) processNode(n *assimp.Node) *Node {
+	node := NewNode(n.Name(), assimpMat4(n.Transformation()))
+	// The node object only contains indices to index the actual objects in the scene.
+	node.Meshes = append(node.Meshes, n.Meshes()...)
+	for _, c := range n.Children() {
+		node.AddChild(m.processNode(c))
+	}
+	return node
+}
+
+func (m *Model) processMeshVertices(mesh *assimp.Mesh) []Vertex {
+	// Walk through each of the mesh's vertices
+	vertices := []Vertex{}
+
+	positions := mesh.Vertices()
+
+	normals := mesh.Normals()
+	useNormals := len(normals) > 0
+
+	tex := mesh.TextureCoords(0)
+	useTex := true
+	if tex == nil {
+		useTex = false
+	}
+
+	tangents := mesh.Tangents()
+	useTangents := len(tangents) > 0
+
+	bitangents := mesh.Bitangents()
+	useBitTangents := len(bitangents) > 0
+
+	for i := 0; i < mesh.NumVertices(); i++ {
+		// We declare a placeholder vector since assimp uses its own vector class that
+		// doesn't directly convert to glm's vec3 class so we transfer the data to this placeholder glm::vec3 first.
+		vertex := Vertex{}
+
+		// Positions
+		vertex.Position = mgl32.Vec3{positions[i].X(), positions[i].Y(), positions[i].Z()}
+
+		// Normals
+		if useNormals {
+			vertex.Normal = mgl32.Vec3{normals[i].X(), normals[i].Y(), normals[i].Z()}
+			//n.WriteString(fmt.Sprintf("[%f, %f, %f]\n", tmp[i].X(), tmp[i].Y(), tmp[i].Z()))
+		}
+
+		// Texture Coordinates
+		if useTex {
+			// Does the mesh contain texture coordinates?
+			// A vertex can contain up to 8 different texture coordinates. We thus make the assumption that we won't
+			// use models where a vertex can have multiple texture coordinates so we always take the first set (0).
+			vertex.TexCoords = mgl32.Vec2{tex[i].X(), tex[i].Y()}
+		} else {
+			vertex.TexCoords = mgl32.Vec2{0.0, 0.0}
+		}
+
+		// Tangent
+		if useTangents {
+			vertex.Tangent = mgl32.Vec3{tangents[i].X(), tangents[i].Y(), tangents[i].Z()}
+		}
+
+		// Bitangent
+		if useBitTangents {
+			vertex.Bitangent = mgl32.Vec3{bitangents[i].X(), bitangents[i].Y(), bitangents[i].Z()}
+		}
+
+		vertices = append(vertices, vertex)
+	}
+
+	return vertices
+}
+
+func (m *Model) processMeshIndices(mesh *assimp.Mesh) []uint32 {
+	indices := []uint32{}
+	// Now wak through each of the mesh's faces (a face is a mesh its triangle) and retrieve the corresponding vertex indices.
+	for i := 0; i < mesh.NumFaces(); i++ {
+		face := mesh.Faces()[i]
+		// Retrieve all indices of the face and store them in the indices vector
+		indices = append(indices, face.CopyIndices()...)
+	}
+	return indices
+}
+
+func (m *Model) processMeshTextures(mesh *assimp.Mesh, s *assimp.Scene) []Texture {
+	textures := []Texture{}
+	// Process materials
+	if mesh.MaterialIndex() >= 0 {
+		material := s.Materials()[mesh.MaterialIndex()]
+
+		// We assume a convention for sampler names in the shaders. Each diffuse texture should be named
+		// as 'texture_diffuseN' where N is a sequential number ranging from 1 to MAX_SAMPLER_NUMBER.
+		// Same applies to other texture as the following list summarizes:
+		// Diffuse: texture_diffuseN
+		// Specular: texture_specularN
+		// Normal: texture_normalN
+
+		// 1. Diffuse maps
+		diffuseMaps := m.loadMaterialTextures(material, assimp.TextureMapping_Diffuse, "texture_diffuse")
+		textures = append(textures, diffuseMaps...)
+		// 2. Specular maps
+		specularMaps := m.loadMaterialTextures(material, assimp.TextureMapping_Specular, "texture_specular")
+		textures = append(textures, specularMaps...)
+		// 3. Normal maps
+		normalMaps := m.loadMaterialTextures(material, assimp.TextureMapping_Height, "texture_normal")
+		textures = append(textures, normalMaps...)
+		// 4. Height maps
+		heightMaps := m.loadMaterialTextures(material, assimp.TextureMapping_Ambient, "texture_height")
+		textures = append(textures, heightMaps...)
+	}
+	return textures
+}
+
+func (m *Model) processMesh(ms *assimp.Mesh, s *assimp.Scene) Mesh {
+	// Return a mesh object created from the extracted mesh data
+	return NewMesh(
+		m.processMeshVertices(ms),
+		m.processMeshIndices(ms),
+		m.processMeshTextures(ms, s))
+}
+
+func (m *Model) loadMaterialTextures(ms *assimp.Material, tm assimp.TextureMapping, tt string) []Texture {
+	textureType := assimp.TextureType(tm)
+	textureCount := ms.GetMaterialTextureCount(textureType)
+	result := []Texture{}
+
+	for i := 0; i < textureCount; i++ {
+		file, _, _, _, _, _, _, _ := ms.GetMaterialTexture(textureType, 0)
+		filename := m.BasePath + file
+		texture := Texture{id: 0, TextureType: tt, Path: filename}
+		result = append(result, texture)
+
+		//if val, ok := m.texturesLoaded[filename]; ok {
+		//	result = append(result, val)
+		//} else {
+		//	texId := m.textureFromFile(filename)
+		//	texture := Texture{id: texId, TextureType: tt, Path: filename}
+		//	result = append(result, texture)
+		//	m.texturesLoaded[filename] = texture
+		//}
+	}
+	return result
+}
+
+// extractDir copies the files of dir in fsys, subdirectories included, to a
+// temporary directory so that a model and its companion files (materials,
+// buffers, textures) can be read by assimp.
+func extractDir(fsys fs.FS, dir string) (string, error) {
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := ioutil.TempDir("", "glutils-model")
+	if err != nil {
+		return "", err
+	}
+	err = fs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, dir), "/")
+		if dir == "." {
+			rel = p
+		}
+		target := filepath.Join(tmp, filepath.FromSlash(rel))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0644)
+	})
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
@@ -0,0 +1,11 @@
+//go:build noassimp
+
+package glutils
+
+import "fmt"
+
+// loadAssimp is not available in builds with the noassimp tag, which only
+// read OBJ, glTF, PLY and STL models and gob caches.
+func (m *Model) loadAssimp() error {
+	return fmt.Errorf("%s: only OBJ, glTF, PLY and STL models can be loaded without assimp", m.FileName)
+}
@@ -0,0 +1,81 @@
+package glutils
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Node is a node of the scene graph of a model. Its meshes are indices in
+// Model.Meshes, drawn with the world matrix of the node.
+type Node struct {
+	Name      string
+	Transform mgl32.Mat4
+	Children  []*Node
+	Meshes    []int
+	parent    *Node
+}
+
+// NewNode returns a node with the local transform t.
+func NewNode(name string, t mgl32.Mat4) *Node {
+	return &Node{Name: name, Transform: t}
+}
+
+// AddChild appends c to the children of n.
+func (n *Node) AddChild(c *Node) {
+	c.parent = n
+	n.Children = append(n.Children, c)
+}
+
+// Parent returns the parent of n, nil for the root.
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// WorldMatrix returns the transform of n relative to the root of its tree.
+func (n *Node) WorldMatrix() mgl32.Mat4 {
+	world := n.Transform
+	for p := n.parent; p != nil; p = p.parent {
+		world = p.Transform.Mul4(world)
+	}
+	return world
+}
+
+// Find returns the first node named name in the tree of n, depth first.
+func (n *Node) Find(name string) *Node {
+	if n.Name == name {
+		return n
+	}
+	for _, c := range n.Children {
+		if found := c.Find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Walk calls fn for n and its descendants, depth first, with their world
+// matrix relative to parent.
+func (n *Node) Walk(parent mgl32.Mat4, fn func(n *Node, world mgl32.Mat4)) {
+	world := parent.Mul4(n.Transform)
+	fn(n, world)
+	for _, c := range n.Children {
+		c.Walk(world, fn)
+	}
+}
+
+// link restores the parents of the tree, which gob files don't store.
+func (n *Node) link() {
+	for _, c := range n.Children {
+		c.parent = n
+		c.link()
+	}
+}
+
+// flatNode returns a root node drawing meshes meshes with no transform, for
+// formats without a hierarchy.
+func flatNode(name string, meshes int) *Node {
+	n := NewNode(name, mgl32.Ident4())
+	for i := 0; i < meshes; i++ {
+		n.Meshes = append(n.Meshes, i)
+	}
+	return n
+}
@@ -0,0 +1,540 @@
+package glutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// OBJMaterial is a material of an MTL library. Texture maps are the file
+// names as written in the library.
+type OBJMaterial struct {
+	Name      string
+	Ambient   mgl32.Vec3
+	Diffuse   mgl32.Vec3
+	Specular  mgl32.Vec3
+	Emissive  mgl32.Vec3
+	Shininess float32
+	Opacity   float32
+
+	AmbientMap  string
+	DiffuseMap  string
+	SpecularMap string
+	BumpMap     string
+}
+
+// Textures returns the texture maps of the material with the texture types
+// used by Model: map_Kd is texture_diffuse, map_Ks texture_specular, map_Bump
+// texture_normal and map_Ka texture_height, as assimp reports them.
+func (mt OBJMaterial) Textures() []Texture {
+	var textures []Texture
+	for _, t := range []struct{ file, textureType string }{
+		{mt.DiffuseMap, "texture_diffuse"},
+		{mt.SpecularMap, "texture_specular"},
+		{mt.BumpMap, "texture_normal"},
+		{mt.AmbientMap, "texture_height"},
+	} {
+		if t.file != "" {
+			textures = append(textures, Texture{TextureType: t.textureType, Path: t.file})
+		}
+	}
+	return textures
+}
+
+// ParseMTL reads the materials of an MTL library.
+func ParseMTL(r io.Reader) (map[string]OBJMaterial, error) {
+	materials := make(map[string]OBJMaterial)
+	var (
+		cur  OBJMaterial
+		open bool
+	)
+	err := scanOBJLines(r, func(line int, keyword string, args []string) error {
+		if keyword == "newmtl" {
+			if open {
+				materials[cur.Name] = cur
+			}
+			cur = OBJMaterial{Name: strings.Join(args, " "), Opacity: 1}
+			open = true
+			return nil
+		}
+		if !open {
+			return nil
+		}
+		var err error
+		switch keyword {
+		case "Ka":
+			cur.Ambient, err = parseOBJVec3(args)
+		case "Kd":
+			cur.Diffuse, err = parseOBJVec3(args)
+		case "Ks":
+			cur.Specular, err = parseOBJVec3(args)
+		case "Ke":
+			cur.Emissive, err = parseOBJVec3(args)
+		case "Ns":
+			cur.Shininess, err = parseOBJFloat(args, 0)
+		case "d":
+			cur.Opacity, err = parseOBJFloat(args, 0)
+		case "Tr":
+			var tr float32
+			tr, err = parseOBJFloat(args, 0)
+			cur.Opacity = 1 - tr
+		case "map_Ka":
+			cur.AmbientMap = mtlMapFile(args)
+		case "map_Kd":
+			cur.DiffuseMap = mtlMapFile(args)
+		case "map_Ks":
+			cur.SpecularMap = mtlMapFile(args)
+		case "map_Bump", "map_bump", "bump", "norm":
+			cur.BumpMap = mtlMapFile(args)
+		}
+		if err != nil {
+			return fmt.Errorf("mtl line %d: %s: %v", line, keyword, err)
+		}
+		return nil
+	})
+	if open {
+		materials[cur.Name] = cur
+	}
+	return materials, err
+}
+
+// mtlMapFile returns the file of a texture map statement, skipping options
+// such as -bm 0.5 or -s 1 1 1.
+func mtlMapFile(args []string) string {
+	for len(args) > 1 && strings.HasPrefix(args[0], "-") {
+		option := args[0]
+		args = args[1:]
+		switch option {
+		case "-o", "-s", "-t":
+			// 1 to 3 numbers
+			for n := 0; n < 3 && len(args) > 1; n++ {
+				if _, err := strconv.ParseFloat(args[0], 32); err != nil {
+					break
+				}
+				args = args[1:]
+			}
+		case "-mm":
+			if len(args) > 2 {
+				args = args[2:]
+			} else {
+				args = args[len(args)-1:]
+			}
+		default:
+			args = args[1:]
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// ParseOBJ reads a Wavefront OBJ file into meshes, one per object, group and
+// material, with their texture maps. Polygons are triangulated, texture
+// coordinates flipped vertically like the assimp loader does, and missing
+// normals computed, smoothed across faces of the same smoothing group.
+// openMTL opens the material libraries the file refers to, it may be nil.
+// No GL calls are made, meshes are set up by Model.
+func ParseOBJ(r io.Reader, openMTL func(name string) (io.ReadCloser, error)) ([]Mesh, error) {
+	p := &objParser{
+		materials: make(map[string]OBJMaterial),
+		groups:    make(map[objGroupKey]*objGroup),
+	}
+	err := scanOBJLines(r, func(line int, keyword string, args []string) error {
+		if err := p.statement(keyword, args, openMTL); err != nil {
+			return fmt.Errorf("obj line %d: %s: %v", line, keyword, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var meshes []Mesh
+	materials := make(map[string]*Material)
+	for _, g := range p.order {
+		if len(g.indices) == 0 {
+			continue
+		}
+		g.computeNormals()
+		mt, ok := p.materials[g.key.material]
+		mesh := NewMesh(g.vertices, g.indices, mt.Textures())
+		mesh.Id = len(meshes)
+		// meshes of the same material share it
+		if ok {
+			if materials[mt.Name] == nil {
+				materials[mt.Name] = mt.Material()
+			}
+			mesh.Material = materials[mt.Name]
+		}
+		meshes = append(meshes, mesh)
+	}
+	return meshes, nil
+}
+
+type objGroupKey struct {
+	object, group, material string
+}
+
+// objVertexKey identifies a vertex of a group. Vertices without a normal are
+// also keyed by smoothing group, and by face when not smoothed.
+type objVertexKey struct {
+	v, vt, vn    int
+	smooth, face int
+}
+
+type objGroup struct {
+	key      objGroupKey
+	vertices []Vertex
+	indices  []uint32
+	cache    map[objVertexKey]uint32
+	// vertices whose normal is computed
+	computed map[uint32]bool
+}
+
+type objParser struct {
+	positions []mgl32.Vec3
+	texCoords []mgl32.Vec2
+	normals   []mgl32.Vec3
+	materials map[string]OBJMaterial
+
+	key    objGroupKey
+	smooth int
+	faces  int
+	groups map[objGroupKey]*objGroup
+	order  []*objGroup
+}
+
+func (p *objParser) statement(keyword string, args []string, openMTL func(string) (io.ReadCloser, error)) error {
+	switch keyword {
+	case "v":
+		v, err := parseOBJVec3(args)
+		p.positions = append(p.positions, v)
+		return err
+	case "vt":
+		u, err := parseOBJFloat(args, 0)
+		if err != nil {
+			return err
+		}
+		v, err := parseOBJFloat(args, 1)
+		p.texCoords = append(p.texCoords, mgl32.Vec2{u, 1 - v})
+		return err
+	case "vn":
+		n, err := parseOBJVec3(args)
+		p.normals = append(p.normals, n)
+		return err
+	case "f":
+		return p.face(args)
+	case "o":
+		p.key.object = strings.Join(args, " ")
+	case "g":
+		p.key.group = strings.Join(args, " ")
+	case "usemtl":
+		p.key.material = strings.Join(args, " ")
+	case "s":
+		p.smooth = 0
+		switch {
+		case len(args) == 0 || args[0] == "off":
+		case args[0] == "on":
+			// a single smoothing group, as "s 1"
+			p.smooth = 1
+		default:
+			s, err := strconv.Atoi(args[0])
+			if err != nil {
+				return err
+			}
+			p.smooth = s
+		}
+	case "mtllib":
+		if openMTL == nil {
+			return nil
+		}
+		// file names can contain spaces, try the whole line first
+		names := append([]string{strings.Join(args, " ")}, args...)
+		for _, name := range names {
+			f, err := openMTL(name)
+			if err != nil {
+				continue
+			}
+			materials, err := ParseMTL(f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			for k, v := range materials {
+				p.materials[k] = v
+			}
+			if name == names[0] {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (p *objParser) group() *objGroup {
+	g, ok := p.groups[p.key]
+	if !ok {
+		g = &objGroup{
+			key:      p.key,
+			cache:    make(map[objVertexKey]uint32),
+			computed: make(map[uint32]bool),
+		}
+		p.groups[p.key] = g
+		p.order = append(p.order, g)
+	}
+	return g
+}
+
+// objIndex resolves a 1-based or negative relative OBJ index into a 0-based
+// one, -1 when s is empty.
+func objIndex(s string, n int) (int, error) {
+	if s == "" {
+		return -1, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case i > 0 && i <= n:
+		return i - 1, nil
+	case i < 0 && -i <= n:
+		return n + i, nil
+	}
+	return 0, fmt.Errorf("index %d out of %d elements", i, n)
+}
+
+func (p *objParser) face(args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("face has %d vertices", len(args))
+	}
+	g := p.group()
+	p.faces++
+
+	polygon := make([]uint32, len(args))
+	points := make([]mgl32.Vec3, len(args))
+	needsNormal := false
+	for i, arg := range args {
+		refs := strings.Split(arg, "/")
+		for len(refs) < 3 {
+			refs = append(refs, "")
+		}
+		v, err := objIndex(refs[0], len(p.positions))
+		if err == nil && v < 0 {
+			err = fmt.Errorf("vertex %q has no position", arg)
+		}
+		if err != nil {
+			return err
+		}
+		vt, err := objIndex(refs[1], len(p.texCoords))
+		if err != nil {
+			return err
+		}
+		vn, err := objIndex(refs[2], len(p.normals))
+		if err != nil {
+			return err
+		}
+
+		key := objVertexKey{v: v, vt: vt, vn: vn}
+		if vn < 0 {
+			needsNormal = true
+			key.smooth = p.smooth
+			if p.smooth == 0 {
+				key.face = p.faces
+			}
+		}
+		idx, ok := g.cache[key]
+		if !ok {
+			vertex := Vertex{Position: p.positions[v]}
+			if vt >= 0 {
+				vertex.TexCoords = p.texCoords[vt]
+			}
+			if vn >= 0 {
+				vertex.Normal = p.normals[vn]
+			}
+			idx = uint32(len(g.vertices))
+			g.vertices = append(g.vertices, vertex)
+			g.cache[key] = idx
+			if vn < 0 {
+				g.computed[idx] = true
+			}
+		}
+		polygon[i] = idx
+		points[i] = p.positions[v]
+	}
+
+	for _, t := range triangulate(points) {
+		a, b, c := polygon[t[0]], polygon[t[1]], polygon[t[2]]
+		g.indices = append(g.indices, a, b, c)
+		if needsNormal {
+			// area weighted face normal
+			n := points[t[1]].Sub(points[t[0]]).Cross(points[t[2]].Sub(points[t[0]]))
+			for _, idx := range []uint32{a, b, c} {
+				if g.computed[idx] {
+					g.vertices[idx].Normal = g.vertices[idx].Normal.Add(n)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (g *objGroup) computeNormals() {
+	for idx := range g.computed {
+		if n := g.vertices[idx].Normal; n.Len() > 0 {
+			g.vertices[idx].Normal = n.Normalize()
+		}
+	}
+}
+
+// triangulate splits a planar polygon into triangles of indices into points,
+// keeping its winding. Concave polygons are handled by ear clipping.
+func triangulate(points []mgl32.Vec3) [][3]int {
+	n := len(points)
+	if n == 3 {
+		return [][3]int{{0, 1, 2}}
+	}
+
+	// project the polygon on the plane of its largest Newell normal component
+	var normal mgl32.Vec3
+	for i := range points {
+		cur, next := points[i], points[(i+1)%n]
+		normal[0] += (cur[1] - next[1]) * (cur[2] + next[2])
+		normal[1] += (cur[2] - next[2]) * (cur[0] + next[0])
+		normal[2] += (cur[0] - next[0]) * (cur[1] + next[1])
+	}
+	ax, ay := 0, 1
+	switch {
+	case abs32(normal[0]) >= abs32(normal[1]) && abs32(normal[0]) >= abs32(normal[2]):
+		ax, ay = 1, 2
+	case abs32(normal[1]) >= abs32(normal[2]):
+		ax, ay = 2, 0
+	}
+	pts := make([]mgl32.Vec2, n)
+	var area float32
+	for i, p := range points {
+		pts[i] = mgl32.Vec2{p[ax], p[ay]}
+	}
+	for i := range pts {
+		cur, next := pts[i], pts[(i+1)%n]
+		area += cur[0]*next[1] - next[0]*cur[1]
+	}
+	// orientation of the polygon in the projection, convex corners turn the
+	// same way
+	sign := float32(1)
+	if area < 0 {
+		sign = -1
+	}
+
+	remaining := make([]int, n)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	var tris [][3]int
+	for len(remaining) > 3 {
+		ear := -1
+		for i := range remaining {
+			a := remaining[(i+len(remaining)-1)%len(remaining)]
+			b := remaining[i]
+			c := remaining[(i+1)%len(remaining)]
+			if sign*cross2(pts[a], pts[b], pts[c]) <= 0 {
+				continue
+			}
+			inside := false
+			for _, o := range remaining {
+				if o != a && o != b && o != c && pointInTriangle(pts[o], pts[a], pts[b], pts[c], sign) {
+					inside = true
+					break
+				}
+			}
+			if !inside {
+				ear = i
+				break
+			}
+		}
+		if ear < 0 {
+			// degenerate polygon, fall back to a fan
+			for i := 1; i < len(remaining)-1; i++ {
+				tris = append(tris, [3]int{remaining[0], remaining[i], remaining[i+1]})
+			}
+			return tris
+		}
+		a := remaining[(ear+len(remaining)-1)%len(remaining)]
+		c := remaining[(ear+1)%len(remaining)]
+		tris = append(tris, [3]int{a, remaining[ear], c})
+		remaining = append(remaining[:ear], remaining[ear+1:]...)
+	}
+	return append(tris, [3]int{remaining[0], remaining[1], remaining[2]})
+}
+
+func cross2(a, b, c mgl32.Vec2) float32 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+func pointInTriangle(p, a, b, c mgl32.Vec2, sign float32) bool {
+	return sign*cross2(a, b, p) >= 0 && sign*cross2(b, c, p) >= 0 && sign*cross2(c, a, p) >= 0
+}
+
+func abs32(f float32) float32 {
+	return float32(math.Abs(float64(f)))
+}
+
+// scanOBJLines calls fn with the keyword and arguments of every statement of
+// an OBJ or MTL file, skipping comments and joining continued lines.
+func scanOBJLines(r io.Reader, fn func(line int, keyword string, args []string) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var (
+		line, start int
+		text        string
+	)
+	for sc.Scan() {
+		line++
+		if text == "" {
+			start = line
+		}
+		s := sc.Text()
+		if strings.HasSuffix(s, "\\") {
+			text += strings.TrimSuffix(s, "\\") + " "
+			continue
+		}
+		s, text = text+s, ""
+		if i := strings.IndexByte(s, '#'); i >= 0 {
+			s = s[:i]
+		}
+		fields := strings.Fields(s)
+		if len(fields) == 0 {
+			continue
+		}
+		if err := fn(start, fields[0], fields[1:]); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+func parseOBJFloat(args []string, i int) (float32, error) {
+	if i >= len(args) {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(args[i], 32)
+	return float32(f), err
+}
+
+func parseOBJVec3(args []string) (mgl32.Vec3, error) {
+	var v mgl32.Vec3
+	if len(args) < 3 {
+		return v, fmt.Errorf("expected 3 components, got %d", len(args))
+	}
+	for i := range v {
+		f, err := parseOBJFloat(args, i)
+		if err != nil {
+			return v, err
+		}
+		v[i] = f
+	}
+	return v, nil
+}
@@ -0,0 +1,177 @@
+package glutils
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func parseOBJString(t *testing.T, obj string, mtl map[string]string) []Mesh {
+	t.Helper()
+	meshes, err := ParseOBJ(strings.NewReader(obj), func(name string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(mtl[name])), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return meshes
+}
+
+func meshArea(m Mesh) float32 {
+	var area float32
+	for i := 0; i+2 < len(m.Indices); i += 3 {
+		a := m.Vertices[m.Indices[i]].Position
+		b := m.Vertices[m.Indices[i+1]].Position
+		c := m.Vertices[m.Indices[i+2]].Position
+		area += b.Sub(a).Cross(c.Sub(a)).Len() / 2
+	}
+	return area
+}
+
+func TestParseOBJQuadNegativeIndices(t *testing.T) {
+	meshes := parseOBJString(t, `
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0.25
+vt 1 0
+vt 1 1
+vt 0 1
+f -4/-4 -3/-3 -2/-2 -1/-1
+`, nil)
+	if len(meshes) != 1 {
+		t.Fatalf("got %d meshes, want 1", len(meshes))
+	}
+	m := meshes[0]
+	if len(m.Vertices) != 4 || len(m.Indices) != 6 {
+		t.Fatalf("got %d vertices and %d indices, want 4 and 6", len(m.Vertices), len(m.Indices))
+	}
+	for _, v := range m.Vertices {
+		if !v.Normal.ApproxEqual(mgl32.Vec3{0, 0, 1}) {
+			t.Errorf("normal %v, want +z", v.Normal)
+		}
+	}
+	// texture coordinates are flipped vertically
+	if tc := m.Vertices[0].TexCoords; !tc.ApproxEqual(mgl32.Vec2{0, 0.75}) {
+		t.Errorf("texture coordinates %v, want [0 0.75]", tc)
+	}
+	if a := meshArea(m); !mgl32.FloatEqual(a, 1) {
+		t.Errorf("area %v, want 1", a)
+	}
+}
+
+func TestParseOBJConcavePolygon(t *testing.T) {
+	// an L shape, whose corner at (1, 1) is reflex
+	meshes := parseOBJString(t, `
+v 0 0 0
+v 2 0 0
+v 2 1 0
+v 1 1 0
+v 1 2 0
+v 0 2 0
+f 1 2 3 4 5 6
+`, nil)
+	m := meshes[0]
+	if len(m.Indices) != 12 {
+		t.Fatalf("got %d triangles, want 4", len(m.Indices)/3)
+	}
+	if a := meshArea(m); !mgl32.FloatEqual(a, 3) {
+		t.Errorf("area %v, want 3", a)
+	}
+	for i := 0; i < len(m.Indices); i += 3 {
+		a := m.Vertices[m.Indices[i]].Position
+		b := m.Vertices[m.Indices[i+1]].Position
+		c := m.Vertices[m.Indices[i+2]].Position
+		if n := b.Sub(a).Cross(c.Sub(a)); n[2] <= 0 {
+			t.Errorf("triangle %d has the wrong winding", i/3)
+		}
+	}
+}
+
+func TestParseOBJSmoothingGroups(t *testing.T) {
+	faces := `
+v 0 0 0
+v 1 0 0
+v 0 1 0
+v 0 0 1
+f 1 2 3
+f 1 4 2
+`
+	for _, c := range []struct {
+		smooth   string
+		vertices int
+	}{
+		{"s off", 6},
+		{"s 0", 6},
+		{"s 1", 4},
+		{"s on", 4},
+	} {
+		m := parseOBJString(t, c.smooth+faces, nil)[0]
+		if len(m.Vertices) != c.vertices {
+			t.Errorf("%s: got %d vertices, want %d", c.smooth, len(m.Vertices), c.vertices)
+		}
+		for _, v := range m.Vertices {
+			if !mgl32.FloatEqualThreshold(v.Normal.Len(), 1, 1e-5) {
+				t.Errorf("%s: normal %v is not normalized", c.smooth, v.Normal)
+			}
+		}
+	}
+}
+
+func TestParseOBJMaterials(t *testing.T) {
+	meshes := parseOBJString(t, `
+mtllib scene.mtl
+v 0 0 0
+v 1 0 0
+v 0 1 0
+f 1 2 3
+usemtl red
+f 1 2 3
+usemtl textured
+f 1 2 3
+`, map[string]string{"scene.mtl": `
+newmtl red
+Kd 1 0 0
+Ns 10
+d 0.5
+
+newmtl textured
+map_Kd -s 1 1 1 diffuse map.png
+map_Bump -bm 0.5 normal.png
+`})
+	if len(meshes) != 3 {
+		t.Fatalf("got %d meshes, want 3", len(meshes))
+	}
+	if meshes[0].Material != nil || meshes[0].Textures != nil {
+		t.Errorf("mesh without material has %v and %v", meshes[0].Material, meshes[0].Textures)
+	}
+	red := meshes[1].Material
+	if red == nil || red.Name != "red" || red.Diffuse != (mgl32.Vec3{1, 0, 0}) || red.Shininess != 10 || red.Opacity != 0.5 {
+		t.Errorf("red material %+v", red)
+	}
+	want := []Texture{
+		{TextureType: "texture_diffuse", Path: "diffuse map.png"},
+		{TextureType: "texture_normal", Path: "normal.png"},
+	}
+	if got := meshes[2].Textures; len(got) != 2 || got[0].TextureType != want[0].TextureType || got[0].Path != want[0].Path ||
+		got[1].TextureType != want[1].TextureType || got[1].Path != want[1].Path {
+		t.Errorf("textures %+v, want %+v", got, want)
+	}
+}
+
+func TestParseOBJErrors(t *testing.T) {
+	for _, obj := range []string{
+		"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 9\n",
+		"v 0 0 0\nv 1 0 0\nf 1 2\n",
+		"v 0 0 x\n",
+		"s x\n",
+	} {
+		if _, err := ParseOBJ(strings.NewReader(obj), nil); err == nil {
+			t.Errorf("no error for %q", obj)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+package glutils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// PLY is the content of a PLY file. Vertices hold the positions and, when the
+// file has them, the normals (nx, ny, nz) and texture coordinates (s, t or u,
+// v), flipped vertically like the other loaders do. Colors are the red, green,
+// blue and alpha properties normalized to [0, 1], nil when absent.
+// Properties holds every vertex property by name, including the ones above.
+// Indices are the triangulated faces, empty for point clouds.
+type PLY struct {
+	Vertices   []Vertex
+	Colors     []mgl32.Vec4
+	Properties map[string][]float32
+	Indices    []uint32
+}
+
+type plyProperty struct {
+	name      string
+	typ       string
+	list      bool
+	countType string
+}
+
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+// plyReader reads values in the format of the file.
+type plyReader struct {
+	r     *bufio.Reader
+	ascii bool
+	order binary.ByteOrder
+	buf   [8]byte
+	line  []string
+}
+
+func plyTypeSize(t string) int {
+	switch t {
+	case "char", "uchar", "int8", "uint8":
+		return 1
+	case "short", "ushort", "int16", "uint16":
+		return 2
+	case "int", "uint", "int32", "uint32", "float", "float32":
+		return 4
+	case "double", "float64":
+		return 8
+	}
+	return 0
+}
+
+func (pr *plyReader) value(t string) (float64, error) {
+	if pr.ascii {
+		for len(pr.line) == 0 {
+			s, err := pr.r.ReadString('\n')
+			if err != nil && (err != io.EOF || s == "") {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return 0, err
+			}
+			pr.line = strings.Fields(s)
+		}
+		s := pr.line[0]
+		pr.line = pr.line[1:]
+		return strconv.ParseFloat(s, 64)
+	}
+
+	size := plyTypeSize(t)
+	b := pr.buf[:size]
+	if _, err := io.ReadFull(pr.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	switch t {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(pr.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(pr.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(pr.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(pr.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(pr.order.Uint32(b))), nil
+	}
+	return math.Float64frombits(pr.order.Uint64(b)), nil
+}
+
+// ParsePLY reads an ASCII or binary PLY file. Faces are triangulated, and
+// normals computed when the file has faces but no normals.
+func ParsePLY(r io.Reader) (*PLY, error) {
+	pr := &plyReader{r: bufio.NewReader(r)}
+	elements, err := pr.header()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PLY{Properties: make(map[string][]float32)}
+	var colorScale map[string]float32
+	for _, e := range elements {
+		switch e.name {
+		case "vertex":
+			colorScale = make(map[string]float32)
+			for _, prop := range e.properties {
+				if !prop.list {
+					p.Properties[prop.name] = make([]float32, 0, e.count)
+				}
+				switch prop.typ {
+				case "uchar", "uint8":
+					colorScale[prop.name] = 255
+				case "ushort", "uint16":
+					colorScale[prop.name] = 65535
+				default:
+					colorScale[prop.name] = 1
+				}
+			}
+			for i := 0; i < e.count; i++ {
+				for _, prop := range e.properties {
+					if prop.list {
+						if _, err := pr.list(prop); err != nil {
+							return nil, fmt.Errorf("ply: vertex %d: %v", i, err)
+						}
+						continue
+					}
+					v, err := pr.value(prop.typ)
+					if err != nil {
+						return nil, fmt.Errorf("ply: vertex %d: %v", i, err)
+					}
+					p.Properties[prop.name] = append(p.Properties[prop.name], float32(v))
+				}
+			}
+		case "face":
+			vertexCount := len(p.Properties["x"])
+			for i := 0; i < e.count; i++ {
+				for _, prop := range e.properties {
+					if !prop.list {
+						if _, err := pr.value(prop.typ); err != nil {
+							return nil, fmt.Errorf("ply: face %d: %v", i, err)
+						}
+						continue
+					}
+					values, err := pr.list(prop)
+					if err != nil {
+						return nil, fmt.Errorf("ply: face %d: %v", i, err)
+					}
+					if prop.name != "vertex_indices" && prop.name != "vertex_index" {
+						continue
+					}
+					if err := p.face(values, vertexCount); err != nil {
+						return nil, fmt.Errorf("ply: face %d: %v", i, err)
+					}
+				}
+			}
+		default:
+			// other elements are read and ignored
+			for i := 0; i < e.count; i++ {
+				for _, prop := range e.properties {
+					if prop.list {
+						_, err = pr.list(prop)
+					} else {
+						_, err = pr.value(prop.typ)
+					}
+					if err != nil {
+						return nil, fmt.Errorf("ply: %s %d: %v", e.name, i, err)
+					}
+				}
+			}
+		}
+	}
+	if err := p.vertices(colorScale); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (pr *plyReader) header() ([]plyElement, error) {
+	var elements []plyElement
+	line := 0
+	for {
+		s, err := pr.r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("ply: header: %v", err)
+		}
+		line++
+		fields := strings.Fields(s)
+		if line == 1 {
+			if len(fields) != 1 || fields[0] != "ply" {
+				return nil, fmt.Errorf("ply: not a PLY file")
+			}
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "format":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("ply: header line %d: missing format", line)
+			}
+			switch fields[1] {
+			case "ascii":
+				pr.ascii = true
+			case "binary_little_endian":
+				pr.order = binary.LittleEndian
+			case "binary_big_endian":
+				pr.order = binary.BigEndian
+			default:
+				return nil, fmt.Errorf("ply: unsupported format %q", fields[1])
+			}
+		case "element":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("ply: header line %d: malformed element", line)
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return nil, fmt.Errorf("ply: header line %d: bad element count %q", line, fields[2])
+			}
+			elements = append(elements, plyElement{name: fields[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return nil, fmt.Errorf("ply: header line %d: property outside of an element", line)
+			}
+			var prop plyProperty
+			switch {
+			case len(fields) == 5 && fields[1] == "list":
+				prop = plyProperty{name: fields[4], typ: fields[3], list: true, countType: fields[2]}
+				if plyTypeSize(prop.countType) == 0 {
+					return nil, fmt.Errorf("ply: header line %d: unknown type %q", line, prop.countType)
+				}
+			case len(fields) == 3:
+				prop = plyProperty{name: fields[2], typ: fields[1]}
+			default:
+				return nil, fmt.Errorf("ply: header line %d: malformed property", line)
+			}
+			if plyTypeSize(prop.typ) == 0 {
+				return nil, fmt.Errorf("ply: header line %d: unknown type %q", line, prop.typ)
+			}
+			e := &elements[len(elements)-1]
+			e.properties = append(e.properties, prop)
+		case "end_header":
+			if !pr.ascii && pr.order == nil {
+				return nil, fmt.Errorf("ply: missing format")
+			}
+			return elements, nil
+		}
+	}
+}
+
+// plyMaxListLength bounds the length of a list property, a corrupt count
+// would otherwise allocate gigabytes before running out of input.
+const plyMaxListLength = 1 << 24
+
+func (pr *plyReader) list(prop plyProperty) ([]float64, error) {
+	n, err := pr.value(prop.countType)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > plyMaxListLength {
+		return nil, fmt.Errorf("bad list length %v", n)
+	}
+	// the values are appended so that the memory grows with the input read
+	values := make([]float64, 0, int(math.Min(n, 64)))
+	for i := 0; i < int(n); i++ {
+		v, err := pr.value(prop.typ)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (p *PLY) face(values []float64, vertexCount int) error {
+	if len(values) < 3 {
+		return nil
+	}
+	polygon := make([]uint32, len(values))
+	points := make([]mgl32.Vec3, len(values))
+	x, y, z := p.Properties["x"], p.Properties["y"], p.Properties["z"]
+	for i, v := range values {
+		if v < 0 || int(v) >= vertexCount {
+			return fmt.Errorf("index %d out of %d vertices", int(v), vertexCount)
+		}
+		polygon[i] = uint32(v)
+		points[i] = mgl32.Vec3{x[int(v)], y[int(v)], z[int(v)]}
+	}
+	for _, t := range triangulate(points) {
+		p.Indices = append(p.Indices, polygon[t[0]], polygon[t[1]], polygon[t[2]])
+	}
+	return nil
+}
+
+// vertices builds Vertices and Colors from the vertex properties.
+func (p *PLY) vertices(colorScale map[string]float32) error {
+	x, y, z := p.Properties["x"], p.Properties["y"], p.Properties["z"]
+	if x == nil || y == nil || z == nil {
+		if len(p.Properties) == 0 {
+			return nil
+		}
+		return fmt.Errorf("ply: vertices have no x, y and z properties")
+	}
+	get := func(names ...string) []float32 {
+		for _, n := range names {
+			if v, ok := p.Properties[n]; ok {
+				return v
+			}
+		}
+		return nil
+	}
+	nx, ny, nz := get("nx"), get("ny"), get("nz")
+	u, v := get("s", "u", "texture_u", "texture_s"), get("t", "v", "texture_v", "texture_t")
+	red, green, blue := get("red", "r", "diffuse_red"), get("green", "g", "diffuse_green"), get("blue", "b", "diffuse_blue")
+	alpha := get("alpha", "a")
+	hasNormals := nx != nil && ny != nil && nz != nil
+
+	p.Vertices = make([]Vertex, len(x))
+	for i := range p.Vertices {
+		vertex := &p.Vertices[i]
+		vertex.Position = mgl32.Vec3{x[i], y[i], z[i]}
+		if hasNormals {
+			vertex.Normal = mgl32.Vec3{nx[i], ny[i], nz[i]}
+		}
+		if u != nil && v != nil {
+			vertex.TexCoords = mgl32.Vec2{u[i], 1 - v[i]}
+		}
+	}
+	if red != nil && green != nil && blue != nil {
+		scale := func(name string) float32 {
+			for _, n := range []string{name, name[:1], "diffuse_" + name} {
+				if s, ok := colorScale[n]; ok {
+					return s
+				}
+			}
+			return 1
+		}
+		rs, gs, bs, as := scale("red"), scale("green"), scale("blue"), scale("alpha")
+		p.Colors = make([]mgl32.Vec4, len(x))
+		for i := range p.Colors {
+			p.Colors[i] = mgl32.Vec4{red[i] / rs, green[i] / gs, blue[i] / bs, 1}
+			if alpha != nil {
+				p.Colors[i][3] = alpha[i] / as
+			}
+		}
+	}
+	if !hasNormals && len(p.Indices) > 0 {
+		smoothNormals(p.Vertices, p.Indices)
+	}
+	return nil
+}
+
+// smoothNormals sets the normal of every vertex to the area weighted average
+// of the normals of the triangles using it.
+func smoothNormals(vertices []Vertex, indices []uint32) {
+	for k := 0; k+2 < len(indices); k += 3 {
+		a, b, c := indices[k], indices[k+1], indices[k+2]
+		n := vertices[b].Position.Sub(vertices[a].Position).Cross(vertices[c].Position.Sub(vertices[a].Position))
+		for _, i := range []uint32{a, b, c} {
+			vertices[i].Normal = vertices[i].Normal.Add(n)
+		}
+	}
+	for i := range vertices {
+		if vertices[i].Normal.Len() > 0 {
+			vertices[i].Normal = vertices[i].Normal.Normalize()
+		}
+	}
+}
+
+// IsPointCloud reports whether the file has vertices but no faces.
+func (p *PLY) IsPointCloud() bool {
+	return len(p.Indices) == 0 && len(p.Vertices) > 0
+}
+
+// Mesh returns the faces of the file as a mesh, or for point clouds a mesh
+// drawing every vertex as a point. Colors are not part of Vertex, use
+// PointCloud to draw them.
+func (p *PLY) Mesh() Mesh {
+	if !p.IsPointCloud() {
+		return NewMesh(p.Vertices, p.Indices, nil)
+	}
+	indices := make([]uint32, len(p.Vertices))
+	for i := range indices {
+		indices[i] = uint32(i)
+	}
+	m := NewMesh(p.Vertices, indices, nil)
+	m.Points = true
+	return m
+}
+
+// PointCloud returns the vertices as a VertexArray drawn as gl.POINTS, to be
+// set up. It holds the positions at positionLoc and the colors, white when
+// the file has none, at colorLoc.
+func (p *PLY) PointCloud(positionLoc, colorLoc uint32) (*VertexArray, error) {
+	positions := make([]float32, 0, len(p.Vertices)*3)
+	colors := make([]float32, 0, len(p.Vertices)*4)
+	for i, v := range p.Vertices {
+		positions = append(positions, v.Position[:]...)
+		if p.Colors != nil {
+			colors = append(colors, p.Colors[i][:]...)
+		} else {
+			colors = append(colors, 1, 1, 1, 1)
+		}
+	}
+	va, err := NewInterleavedVertexArray(
+		VertexStream{Name: "position", Location: positionLoc, Size: 3, Data: positions},
+		VertexStream{Name: "color", Location: colorLoc, Size: 4, Data: colors},
+	)
+	if err != nil {
+		return nil, err
+	}
+	va.SetPrimitive(gl.POINTS)
+	return va, nil
+}
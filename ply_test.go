@@ -0,0 +1,145 @@
+package glutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const plyTestHeader = `ply
+format %s 1.0
+comment a unit quad with colors
+element vertex 4
+property float x
+property float y
+property float z
+property float s
+property float t
+property uchar red
+property uchar green
+property uchar blue
+element face 1
+property list uchar int vertex_indices
+end_header
+`
+
+var plyTestVertices = [][]float32{
+	{0, 0, 0, 0, 0},
+	{1, 0, 0, 1, 0},
+	{1, 1, 0, 1, 1},
+	{0, 1, 0, 0, 1},
+}
+
+var plyTestColors = [][3]byte{{255, 0, 0}, {0, 255, 0}, {0, 0, 255}, {255, 255, 255}}
+
+func binaryPLY(order binary.ByteOrder, name string) []byte {
+	var b bytes.Buffer
+	b.WriteString(strings.Replace(plyTestHeader, "%s", name, 1))
+	for i, v := range plyTestVertices {
+		binary.Write(&b, order, v)
+		b.Write(plyTestColors[i][:])
+	}
+	b.WriteByte(4)
+	binary.Write(&b, order, []int32{0, 1, 2, 3})
+	return b.Bytes()
+}
+
+func TestParsePLYFormats(t *testing.T) {
+	ascii := strings.Replace(plyTestHeader, "%s", "ascii", 1) + `0 0 0 0 0 255 0 0
+1 0 0 1 0 0 255 0
+1 1 0 1 1 0 0 255
+0 1 0 0 1 255 255 255
+4 0 1 2 3
+`
+	files := map[string][]byte{
+		"ascii":                []byte(ascii),
+		"binary_little_endian": binaryPLY(binary.LittleEndian, "binary_little_endian"),
+		"binary_big_endian":    binaryPLY(binary.BigEndian, "binary_big_endian"),
+	}
+	for name, data := range files {
+		p, err := ParsePLY(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if p.IsPointCloud() {
+			t.Errorf("%s: parsed as a point cloud", name)
+		}
+		if len(p.Indices) != 6 {
+			t.Errorf("%s: indices %v, want 2 triangles", name, p.Indices)
+		}
+		for i, v := range p.Vertices {
+			src := plyTestVertices[i]
+			if v.Position != (mgl32.Vec3{src[0], src[1], src[2]}) {
+				t.Errorf("%s: vertex %d at %v", name, i, v.Position)
+			}
+			// texture coordinates are flipped vertically
+			if v.TexCoords != (mgl32.Vec2{src[3], 1 - src[4]}) {
+				t.Errorf("%s: vertex %d texture coordinates %v", name, i, v.TexCoords)
+			}
+			if !v.Normal.ApproxEqual(mgl32.Vec3{0, 0, 1}) {
+				t.Errorf("%s: vertex %d normal %v, want +z", name, i, v.Normal)
+			}
+		}
+		wantColors := []mgl32.Vec4{{1, 0, 0, 1}, {0, 1, 0, 1}, {0, 0, 1, 1}, {1, 1, 1, 1}}
+		if !reflect.DeepEqual(p.Colors, wantColors) {
+			t.Errorf("%s: colors %v, want %v", name, p.Colors, wantColors)
+		}
+		if got := p.Properties["red"]; !reflect.DeepEqual(got, []float32{255, 0, 0, 255}) {
+			t.Errorf("%s: red property %v", name, got)
+		}
+	}
+}
+
+func TestParsePLYPointCloud(t *testing.T) {
+	p, err := ParsePLY(strings.NewReader(`ply
+format ascii 1.0
+element vertex 3
+property double x
+property double y
+property double z
+property float nx
+property float ny
+property float nz
+end_header
+0 0 0 0 0 1
+1 2 3 0 1 0
+-1 -2 -3 1 0 0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.IsPointCloud() || p.Colors != nil {
+		t.Fatalf("point cloud %v, colors %v", p.IsPointCloud(), p.Colors)
+	}
+	m := p.Mesh()
+	if !m.Points || !reflect.DeepEqual(m.Indices, []uint32{0, 1, 2}) {
+		t.Errorf("mesh points %v, indices %v", m.Points, m.Indices)
+	}
+	if m.Vertices[1].Normal != (mgl32.Vec3{0, 1, 0}) {
+		t.Errorf("normal %v, want the file normal", m.Vertices[1].Normal)
+	}
+}
+
+func TestParsePLYErrors(t *testing.T) {
+	for _, ply := range []string{
+		"obj\n",
+		"ply\nformat binary_middle_endian 1.0\nend_header\n",
+		"ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nproperty float z\n" +
+			"element face 1\nproperty list uchar int vertex_indices\nend_header\n0 0 0\n3 0 1 2\n",
+		"ply\nformat ascii 1.0\nelement vertex 2\nproperty float x\nproperty float y\nproperty float z\nend_header\n0 0 0\n",
+		"ply\nformat binary_little_endian 1.0\nelement vertex 1\nproperty float x\nend_header\n\x00\x00",
+		// list lengths past the maximum and past the end of the input
+		"ply\nformat binary_little_endian 1.0\nelement face 1\nproperty list uint int vertex_indices\nend_header\n\xff\xff\xff\xff",
+		"ply\nformat binary_little_endian 1.0\nelement face 1\nproperty list uint int vertex_indices\nend_header\n\x00\x00\x10\x00\x00",
+		"ply\nformat ascii 1.0\nelement face 1\nproperty list int int vertex_indices\nend_header\n-3 0 1 2\n",
+	} {
+		if _, err := ParsePLY(strings.NewReader(ply)); err == nil {
+			t.Errorf("no error for %q", ply)
+		}
+	}
+}
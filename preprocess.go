@@ -0,0 +1,317 @@
+package glutils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ShaderSource is the result of running a shader file through the Preprocessor.
+// Files lists every file that contributed to Source; the index of a file in
+// the slice is the source string number used in the emitted #line directives,
+// so driver logs such as "0(12)" or "2:7(3)" can be mapped back to a file.
+type ShaderSource struct {
+	File   string
+	Source string
+	Files  []string
+}
+
+// FileName returns the file for a #line source string number.
+func (s ShaderSource) FileName(index int) string {
+	if index < 0 || index >= len(s.Files) {
+		return s.File
+	}
+	return s.Files[index]
+}
+
+// Line returns the text of a line in one of the files, as seen by the
+// compiler after preprocessing. Lines are numbered from 1.
+func (s ShaderSource) Line(index, line int) (string, bool) {
+	cur, num := 0, 1
+	scanner := bufio.NewScanner(strings.NewReader(strings.TrimRight(s.Source, "\x00")))
+	for scanner.Scan() {
+		text := scanner.Text()
+		if directive, arg := parseDirective(text); directive == "line" {
+			f := strings.Fields(arg)
+			if len(f) > 0 {
+				num, _ = strconv.Atoi(f[0])
+			}
+			if len(f) > 1 {
+				cur, _ = strconv.Atoi(f[1])
+			}
+			continue
+		}
+		if cur == index && num == line {
+			return text, true
+		}
+		num++
+	}
+	return "", false
+}
+
+// Preprocessor resolves #include "file" and #import "file" directives in GLSL
+// sources. Quoted paths are looked up relative to the including file first and
+// then in IncludePaths; <file> paths only use IncludePaths.
+// Every file is included at most once.
+// When FS is set, files and include paths are resolved inside it instead of
+// the OS file system.
+// Defines are injected as #define lines right after the #version line of the
+// root file, in name order.
+type Preprocessor struct {
+	IncludePaths []string
+	FS           fs.FS
+	Defines      map[string]string
+}
+
+// DefaultPreprocessor is used by NewShader.
+var DefaultPreprocessor = &Preprocessor{}
+
+func NewPreprocessor(includePaths ...string) *Preprocessor {
+	return &Preprocessor{IncludePaths: includePaths}
+}
+
+// WithFS returns a copy of the preprocessor reading from fsys.
+func (p *Preprocessor) WithFS(fsys fs.FS) *Preprocessor {
+	c := *p
+	c.FS = fsys
+	return &c
+}
+
+// WithDefines returns a copy of the preprocessor injecting defines.
+func (p *Preprocessor) WithDefines(defines map[string]string) *Preprocessor {
+	c := *p
+	c.Defines = defines
+	return &c
+}
+
+// Process reads file and returns it with all includes expanded.
+func (p *Preprocessor) Process(file string) (ShaderSource, error) {
+	return p.run(file, nil)
+}
+
+// ProcessString expands the includes of an in-memory source. name is used to
+// resolve relative includes and in error messages, and can be empty.
+func (p *Preprocessor) ProcessString(name, src string) (ShaderSource, error) {
+	return p.run(name, []byte(src))
+}
+
+func (p *Preprocessor) run(file string, src []byte) (ShaderSource, error) {
+	state := &preprocessState{
+		index: make(map[string]int),
+	}
+	var out bytes.Buffer
+	if err := p.process(file, src, &out, state); err != nil {
+		return ShaderSource{}, err
+	}
+	return ShaderSource{
+		File:   file,
+		Source: out.String(),
+		Files:  state.files,
+	}, nil
+}
+
+type preprocessState struct {
+	files []string
+	index map[string]int
+	stack []string
+}
+
+func (p *Preprocessor) process(file string, src []byte, out *bytes.Buffer, s *preprocessState) error {
+	key, err := p.key(file)
+	if err != nil {
+		return err
+	}
+	for _, f := range s.stack {
+		if f == key {
+			return fmt.Errorf("include cycle: %s -> %s", strings.Join(s.stack, " -> "), key)
+		}
+	}
+	if _, ok := s.index[key]; ok {
+		// already included
+		return nil
+	}
+
+	if src == nil {
+		if src, err = p.readFile(file); err != nil {
+			return err
+		}
+	}
+
+	num := len(s.files)
+	s.index[key] = num
+	s.files = append(s.files, file)
+	s.stack = append(s.stack, key)
+	defer func() { s.stack = s.stack[:len(s.stack)-1] }()
+
+	// the root file's #version must stay the first statement, so its #line
+	// is emitted right after it. Included files start with one.
+	if num > 0 {
+		fmt.Fprintf(out, "#line 1 %d\n", num)
+	} else if len(p.Defines) > 0 && !hasVersion(src) {
+		p.writeDefines(out)
+		out.WriteString("#line 1 0\n")
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		directive, arg := parseDirective(text)
+
+		switch directive {
+		case "version":
+			if num > 0 {
+				// only the root file can declare the version
+				out.WriteString("\n")
+				continue
+			}
+			out.WriteString(text + "\n")
+			p.writeDefines(out)
+			fmt.Fprintf(out, "#line %d %d\n", line+1, num)
+		case "include", "import":
+			inc, err := p.resolve(file, arg)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %v", file, line, err)
+			}
+			before := out.Len()
+			if err := p.process(inc, nil, out, s); err != nil {
+				return err
+			}
+			if out.Len() != before {
+				fmt.Fprintf(out, "#line %d %d\n", line+1, num)
+			} else {
+				out.WriteString("\n")
+			}
+		default:
+			out.WriteString(text + "\n")
+		}
+	}
+	return scanner.Err()
+}
+
+func (p *Preprocessor) writeDefines(out *bytes.Buffer) {
+	names := make([]string, 0, len(p.Defines))
+	for name := range p.Defines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if v := p.Defines[name]; v != "" {
+			fmt.Fprintf(out, "#define %s %s\n", name, v)
+		} else {
+			fmt.Fprintf(out, "#define %s\n", name)
+		}
+	}
+}
+
+func hasVersion(src []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		if directive, _ := parseDirective(scanner.Text()); directive == "version" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDirective returns the preprocessor directive name and its argument for
+// a line such as `#include "light.glsl"`, or "" if the line is not one.
+func parseDirective(line string) (string, string) {
+	t := strings.TrimSpace(line)
+	if !strings.HasPrefix(t, "#") {
+		return "", ""
+	}
+	t = strings.TrimSpace(t[1:])
+	i := strings.IndexAny(t, " \t\"<")
+	if i < 0 {
+		return t, ""
+	}
+	return t[:i], strings.TrimSpace(t[i:])
+}
+
+// resolve finds the file referenced by an include argument.
+func (p *Preprocessor) resolve(from, arg string) (string, error) {
+	if len(arg) < 2 {
+		return "", fmt.Errorf("malformed include %q", arg)
+	}
+	var (
+		name     string
+		relative bool
+	)
+	switch {
+	case arg[0] == '"' && strings.IndexByte(arg[1:], '"') >= 0:
+		name = arg[1 : 1+strings.IndexByte(arg[1:], '"')]
+		relative = true
+	case arg[0] == '<' && strings.IndexByte(arg, '>') >= 0:
+		name = arg[1:strings.IndexByte(arg, '>')]
+	default:
+		return "", fmt.Errorf("malformed include %q", arg)
+	}
+
+	var candidates []string
+	if relative {
+		candidates = append(candidates, p.join(p.dir(from), name))
+	}
+	for _, dir := range p.IncludePaths {
+		candidates = append(candidates, p.join(dir, name))
+	}
+	for _, c := range candidates {
+		if p.isFile(c) {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("include %q not found", name)
+}
+
+// key identifies a file for cycle detection and de-duplication.
+func (p *Preprocessor) key(file string) (string, error) {
+	if p.FS != nil {
+		return path.Clean(file), nil
+	}
+	return filepath.Abs(file)
+}
+
+func (p *Preprocessor) dir(file string) string {
+	if p.FS != nil {
+		return path.Dir(file)
+	}
+	return filepath.Dir(file)
+}
+
+func (p *Preprocessor) join(elem ...string) string {
+	if p.FS != nil {
+		return path.Join(elem...)
+	}
+	return filepath.Join(elem...)
+}
+
+func (p *Preprocessor) isFile(file string) bool {
+	var (
+		fi  fs.FileInfo
+		err error
+	)
+	if p.FS != nil {
+		fi, err = fs.Stat(p.FS, file)
+	} else {
+		fi, err = os.Stat(file)
+	}
+	return err == nil && !fi.IsDir()
+}
+
+func (p *Preprocessor) readFile(file string) ([]byte, error) {
+	if p.FS != nil {
+		return fs.ReadFile(p.FS, file)
+	}
+	if _, err := os.Stat(file); err != nil {
+		return nil, err
+	}
+	return readFile(file)
+}
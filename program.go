@@ -0,0 +1,292 @@
+package glutils
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// ProgramBuilder collects the stages of a program and compiles and links them.
+// Any combination of gl.VERTEX_SHADER, gl.TESS_CONTROL_SHADER,
+// gl.TESS_EVALUATION_SHADER, gl.GEOMETRY_SHADER and gl.FRAGMENT_SHADER can be
+// used, each stage coming from a file, a string or an fs.FS.
+//
+//	shader, err := glutils.NewProgramBuilder().
+//		AddFile(gl.VERTEX_SHADER, "terrain.vs").
+//		AddFile(gl.TESS_CONTROL_SHADER, "terrain.tcs").
+//		AddFile(gl.TESS_EVALUATION_SHADER, "terrain.tes").
+//		AddFile(gl.FRAGMENT_SHADER, "terrain.frag").
+//		Build()
+type ProgramBuilder struct {
+	// Preprocessor expands #include directives, DefaultPreprocessor when nil.
+	Preprocessor *Preprocessor
+	// Cache stores linked program binaries, DefaultProgramCache when nil.
+	Cache *ProgramCache
+	// Defines are injected after the #version line of every stage.
+	Defines map[string]string
+	stages  []programStage
+}
+
+type programStage struct {
+	shaderType uint32
+	file       string
+	source     string
+	fsys       fs.FS
+	isSource   bool
+}
+
+func NewProgramBuilder() *ProgramBuilder {
+	return &ProgramBuilder{}
+}
+
+// AddFile adds a stage read from a file on disk.
+func (b *ProgramBuilder) AddFile(shaderType uint32, file string) *ProgramBuilder {
+	b.stages = append(b.stages, programStage{shaderType: shaderType, file: file})
+	return b
+}
+
+// AddSource adds a stage from a source string.
+func (b *ProgramBuilder) AddSource(shaderType uint32, source string) *ProgramBuilder {
+	b.stages = append(b.stages, programStage{shaderType: shaderType, source: source, isSource: true})
+	return b
+}
+
+// AddFS adds a stage read from fsys. Includes are resolved inside fsys too.
+func (b *ProgramBuilder) AddFS(shaderType uint32, fsys fs.FS, file string) *ProgramBuilder {
+	b.stages = append(b.stages, programStage{shaderType: shaderType, file: file, fsys: fsys})
+	return b
+}
+
+// Define adds a #define injected in every stage.
+func (b *ProgramBuilder) Define(name, value string) *ProgramBuilder {
+	if b.Defines == nil {
+		b.Defines = make(map[string]string)
+	}
+	b.Defines[name] = value
+	return b
+}
+
+func (b *ProgramBuilder) preprocessor() *Preprocessor {
+	pp := DefaultPreprocessor
+	if b.Preprocessor != nil {
+		pp = b.Preprocessor
+	}
+	if len(b.Defines) > 0 {
+		pp = pp.WithDefines(b.Defines)
+	}
+	return pp
+}
+
+// validate checks that the stages form a program that can be linked.
+func (b *ProgramBuilder) validate() error {
+	seen := make(map[uint32]bool)
+	for _, st := range b.stages {
+		switch st.shaderType {
+		case gl.VERTEX_SHADER, gl.TESS_CONTROL_SHADER, gl.TESS_EVALUATION_SHADER, gl.GEOMETRY_SHADER, gl.FRAGMENT_SHADER:
+		default:
+			return fmt.Errorf("unsupported shader type 0x%x", st.shaderType)
+		}
+		if seen[st.shaderType] {
+			return fmt.Errorf("duplicate %s shader stage", ShaderStageName(st.shaderType))
+		}
+		seen[st.shaderType] = true
+	}
+	if !seen[gl.VERTEX_SHADER] {
+		return fmt.Errorf("program has no vertex shader stage")
+	}
+	if seen[gl.TESS_CONTROL_SHADER] && !seen[gl.TESS_EVALUATION_SHADER] {
+		return fmt.Errorf("tessellation control shader requires a tessellation evaluation shader")
+	}
+	return nil
+}
+
+// Sources preprocesses every stage and returns the sources in the order the
+// stages were added.
+func (b *ProgramBuilder) Sources() ([]ShaderSource, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	pp := b.preprocessor()
+	sources := make([]ShaderSource, len(b.stages))
+	for i, st := range b.stages {
+		var (
+			src ShaderSource
+			err error
+		)
+		switch {
+		case st.isSource:
+			src, err = pp.ProcessString("", st.source)
+		case st.fsys != nil:
+			src, err = pp.WithFS(st.fsys).Process(st.file)
+		default:
+			src, err = pp.Process(st.file)
+		}
+		if err != nil {
+			return nil, err
+		}
+		sources[i] = src
+	}
+	return sources, nil
+}
+
+// Link compiles and links all stages and returns the program.
+func (b *ProgramBuilder) Link() (uint32, error) {
+	sources, err := b.Sources()
+	if err != nil {
+		return 0, err
+	}
+	return b.link(sources)
+}
+
+func (b *ProgramBuilder) cache() *ProgramCache {
+	if b.Cache != nil {
+		return b.Cache
+	}
+	return DefaultProgramCache
+}
+
+func (b *ProgramBuilder) link(sources []ShaderSource) (uint32, error) {
+	var key string
+	cache := b.cache()
+	if cache != nil && !cache.supported() {
+		cache = nil
+	}
+	if cache != nil {
+		types := make([]uint32, len(b.stages))
+		for i, st := range b.stages {
+			types[i] = st.shaderType
+		}
+		key = cache.key(types, sources)
+		if p := cache.load(key); p != 0 {
+			return p, nil
+		}
+	}
+
+	shaders := make([]uint32, 0, len(sources))
+	defer func() {
+		for _, s := range shaders {
+			gl.DeleteShader(s)
+		}
+	}()
+	for i, src := range sources {
+		s, err := compileShader(src, b.stages[i].shaderType)
+		if err != nil {
+			return 0, err
+		}
+		shaders = append(shaders, s)
+	}
+	p, err := linkProgram(cache != nil, shaders...)
+	if err != nil {
+		return 0, err
+	}
+	if cache != nil {
+		// a failed write only costs a recompile next time
+		cache.store(key, p)
+	}
+	return p, nil
+}
+
+// Build compiles and links all stages and reflects the program's uniforms and
+// attributes. The returned Shader can be reloaded from the same stages.
+func (b *ProgramBuilder) Build() (Shader, error) {
+	sources, err := b.Sources()
+	if err != nil {
+		return Shader{}, err
+	}
+	p, err := b.link(sources)
+	if err != nil {
+		return Shader{}, err
+	}
+	shader := setupShader(p)
+	shader.builder = b
+	shader.Defines = b.Defines
+	for i, st := range b.stages {
+		// only files on disk can be watched
+		if st.isSource || st.fsys != nil {
+			continue
+		}
+		shader.files = append(shader.files, sources[i].Files...)
+	}
+	return shader, nil
+}
+
+func compileShader(src ShaderSource, shaderType uint32) (uint32, error) {
+	shader := gl.CreateShader(shaderType)
+
+	source := src.Source
+	if !strings.HasSuffix(source, "\x00") {
+		source += "\x00"
+	}
+	csources, free := gl.Strs(source)
+	gl.ShaderSource(shader, 1, csources, nil)
+	free()
+	gl.CompileShader(shader)
+
+	var status int32
+	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
+
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+		gl.DeleteShader(shader)
+
+		return 0, newShaderError(shaderType, src, log)
+	}
+
+	return shader, nil
+}
+
+// linkProgram links the compiled shaders. When retrievable is set the driver
+// is told the binary will be read back with glGetProgramBinary.
+func linkProgram(retrievable bool, shaders ...uint32) (uint32, error) {
+	program := gl.CreateProgram()
+	if retrievable {
+		gl.ProgramParameteri(program, gl.PROGRAM_BINARY_RETRIEVABLE_HINT, gl.TRUE)
+	}
+	for _, s := range shaders {
+		gl.AttachShader(program, s)
+	}
+
+	gl.LinkProgram(program)
+	// the shaders are no longer needed once the program is linked
+	for _, s := range shaders {
+		gl.DetachShader(program, s)
+	}
+
+	// check for program linking errors
+	var status int32
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		gl.DeleteProgram(program)
+
+		return 0, newShaderError(0, ShaderSource{}, log)
+	}
+
+	return program, nil
+}
+
+// ShaderStageName returns a readable name for a GL shader type.
+func ShaderStageName(shaderType uint32) string {
+	switch shaderType {
+	case gl.VERTEX_SHADER:
+		return "vertex"
+	case gl.TESS_CONTROL_SHADER:
+		return "tessellation control"
+	case gl.TESS_EVALUATION_SHADER:
+		return "tessellation evaluation"
+	case gl.GEOMETRY_SHADER:
+		return "geometry"
+	case gl.FRAGMENT_SHADER:
+		return "fragment"
+	}
+	return "unknown"
+}
@@ -0,0 +1,132 @@
+package glutils
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// ProgramCache stores linked program binaries on disk, keyed by a hash of the
+// preprocessed stage sources and the GL vendor, renderer and version strings.
+// Binaries rejected by the driver, e.g. after a driver update, are discarded
+// and the program is compiled from source again.
+type ProgramCache struct {
+	Dir string
+}
+
+// DefaultProgramCache is used by every ProgramBuilder without its own Cache,
+// including the ones created by NewShader. Caching is disabled when nil.
+var DefaultProgramCache *ProgramCache
+
+func NewProgramCache(dir string) (*ProgramCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &ProgramCache{Dir: dir}, nil
+}
+
+type programBinary struct {
+	Format uint32
+	Binary []byte
+}
+
+// supported reports whether the driver can retrieve program binaries at all.
+func (c *ProgramCache) supported() bool {
+	var n int32
+	gl.GetIntegerv(gl.NUM_PROGRAM_BINARY_FORMATS, &n)
+	return n > 0
+}
+
+func (c *ProgramCache) key(types []uint32, sources []ShaderSource) string {
+	h := sha256.New()
+	for _, name := range []uint32{gl.VENDOR, gl.RENDERER, gl.VERSION} {
+		h.Write([]byte(gl.GoStr(gl.GetString(name))))
+		h.Write([]byte{0})
+	}
+	for i, src := range sources {
+		binary.Write(h, binary.LittleEndian, types[i])
+		h.Write([]byte(src.Source))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *ProgramCache) path(key string) string {
+	return filepath.Join(c.Dir, key+".bin")
+}
+
+// load creates a program from a cached binary. It returns 0 if there is no
+// binary for key or if the driver rejected it.
+func (c *ProgramCache) load(key string) uint32 {
+	f, err := os.Open(c.path(key))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	var pb programBinary
+	if err := gob.NewDecoder(f).Decode(&pb); err != nil || len(pb.Binary) == 0 {
+		os.Remove(c.path(key))
+		return 0
+	}
+
+	program := gl.CreateProgram()
+	gl.ProgramBinary(program, pb.Format, gl.Ptr(pb.Binary), int32(len(pb.Binary)))
+
+	var status int32
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		gl.DeleteProgram(program)
+		os.Remove(c.path(key))
+		return 0
+	}
+	return program
+}
+
+// store writes the binary of a linked program to the cache.
+func (c *ProgramCache) store(key string, program uint32) error {
+	var length int32
+	gl.GetProgramiv(program, gl.PROGRAM_BINARY_LENGTH, &length)
+	if length == 0 {
+		return nil
+	}
+	pb := programBinary{Binary: make([]byte, length)}
+	gl.GetProgramBinary(program, length, nil, &pb.Format, gl.Ptr(pb.Binary))
+
+	// write to a temporary file first so a crash never leaves a truncated binary
+	tmp := c.path(key) + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(f).Encode(pb)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Clear removes every cached binary.
+func (c *ProgramCache) Clear() error {
+	files, err := filepath.Glob(filepath.Join(c.Dir, "*.bin"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
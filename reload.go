@@ -0,0 +1,100 @@
+package glutils
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// ShaderReloader polls the files of a Shader, including the files pulled in by
+// #include, and recompiles the program when one of them changes.
+// Polling happens on a background goroutine but the recompilation only happens
+// when Update is called, which must be on the thread owning the GL context.
+type ShaderReloader struct {
+	Shader *Shader
+
+	mu       sync.Mutex
+	files    []string
+	modTimes map[string]time.Time
+	changed  chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Watch starts polling the shader's source files every interval.
+func (s *Shader) Watch(interval time.Duration) *ShaderReloader {
+	r := &ShaderReloader{
+		Shader:   s,
+		files:    s.Files(),
+		modTimes: make(map[string]time.Time),
+		changed:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	r.poll()
+	go r.run(interval)
+	return r
+}
+
+func (r *ShaderReloader) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			if r.poll() {
+				select {
+				case r.changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// poll records the modification time of every watched file and reports
+// whether any of them changed since the previous poll.
+func (r *ShaderReloader) poll() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := false
+	for _, f := range r.files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			// the file may be in the middle of being saved
+			continue
+		}
+		if t, ok := r.modTimes[f]; ok && !t.Equal(fi.ModTime()) {
+			changed = true
+		}
+		r.modTimes[f] = fi.ModTime()
+	}
+	return changed
+}
+
+// Update recompiles the shader if one of its files changed since the last call.
+// It reports whether the program was swapped. When compilation fails the
+// previous program stays in use and the error is returned.
+// It must be called on the thread owning the GL context, e.g. once per frame.
+func (r *ShaderReloader) Update() (bool, error) {
+	select {
+	case <-r.changed:
+	default:
+		return false, nil
+	}
+	if err := r.Shader.Reload(); err != nil {
+		return false, err
+	}
+	// includes may have been added or removed
+	r.mu.Lock()
+	r.files = r.Shader.Files()
+	r.mu.Unlock()
+	r.poll()
+	return true, nil
+}
+
+// Stop stops polling the files.
+func (r *ShaderReloader) Stop() {
+	r.stopOnce.Do(func() { close(r.done) })
+}
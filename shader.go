@@ -3,72 +3,136 @@ package glutils
 import "C"
 import (
 	"fmt"
+	"io/fs"
 	"strings"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 )
 
 func BasicProgram(vertexShaderSource, fragmentShaderSource string) (uint32, error) {
-	vertexShader, err := compileShader(vertexShaderSource, gl.VERTEX_SHADER)
-	if err != nil {
-		return 0, err
-	}
+	return NewProgramBuilder().
+		AddSource(gl.VERTEX_SHADER, vertexShaderSource).
+		AddSource(gl.FRAGMENT_SHADER, fragmentShaderSource).
+		Link()
+}
 
-	fragmentShader, err := compileShader(fragmentShaderSource, gl.FRAGMENT_SHADER)
-	if err != nil {
-		return 0, err
+func NewShader(vertFile, fragFile, geomFile string) (Shader, error) {
+	b := NewProgramBuilder().
+		AddFile(gl.VERTEX_SHADER, vertFile).
+		AddFile(gl.FRAGMENT_SHADER, fragFile)
+	if geomFile != "" {
+		b.AddFile(gl.GEOMETRY_SHADER, geomFile)
 	}
+	return b.Build()
+}
 
-	program := gl.CreateProgram()
-
-	gl.AttachShader(program, vertexShader)
-	gl.AttachShader(program, fragmentShader)
-	gl.LinkProgram(program)
-
-	var status int32
-	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
-	if status == gl.FALSE {
-		var logLength int32
-		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
-
-		log := strings.Repeat("\x00", int(logLength+1))
-		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
-
-		return 0, fmt.Errorf("failed to link program: %v", log)
+// NewShaderFS is NewShader reading the files, and the files they include,
+// from fsys, such as an embed.FS.
+func NewShaderFS(fsys fs.FS, vertFile, fragFile, geomFile string) (Shader, error) {
+	b := NewProgramBuilder().
+		AddFS(gl.VERTEX_SHADER, fsys, vertFile).
+		AddFS(gl.FRAGMENT_SHADER, fsys, fragFile)
+	if geomFile != "" {
+		b.AddFS(gl.GEOMETRY_SHADER, fsys, geomFile)
 	}
+	return b.Build()
+}
 
-	gl.DeleteShader(vertexShader)
-	gl.DeleteShader(fragmentShader)
-
-	return program, nil
+// NewShaderWithDefines builds a variant of a shader with defines injected
+// after the #version line of every stage.
+func NewShaderWithDefines(vertFile, fragFile, geomFile string, defines map[string]string) (Shader, error) {
+	b := NewProgramBuilder().
+		AddFile(gl.VERTEX_SHADER, vertFile).
+		AddFile(gl.FRAGMENT_SHADER, fragFile)
+	if geomFile != "" {
+		b.AddFile(gl.GEOMETRY_SHADER, geomFile)
+	}
+	b.Defines = defines
+	return b.Build()
 }
 
-func NewShader(vertFile, fragFile, geomFile string) (Shader, error) {
-	var shader Shader
-	vertSrc, err := readFile(vertFile)
+// Reload recompiles the shader from the stages it was built with. If
+// compilation fails the current program is kept and the error is returned.
+// It must be called on the thread owning the GL context.
+//
+// The program and its uniform and attribute locations change on reload. The
+// maps of s are updated in place, locations copied out of them or queried
+// from GL must be looked up again. Uniform blocks keep the binding points set
+// with BindUniformBlock, blocks that are no longer active lose them.
+func (s *Shader) Reload() error {
+	if s.builder == nil {
+		return fmt.Errorf("shader %d was not created by a ProgramBuilder", s.Program)
+	}
+	shader, err := s.builder.Build()
 	if err != nil {
-		return shader, err
+		return err
+	}
+	// keep the bindings set with BindUniformBlock
+	for name, old := range s.UniformBlocks {
+		if b, ok := shader.UniformBlocks[name]; ok && b.Binding != old.Binding {
+			gl.UniformBlockBinding(shader.Program, b.Index, old.Binding)
+			b.Binding = old.Binding
+			shader.UniformBlocks[name] = b
+		}
 	}
+	s.Delete()
+	s.Program = shader.Program
+	s.files = shader.files
 
-	fragSrc, err := readFile(fragFile)
-	if err != nil {
-		return shader, err
+	if s.Uniforms == nil {
+		s.Uniforms = make(map[string]int32)
+	}
+	for k := range s.Uniforms {
+		delete(s.Uniforms, k)
+	}
+	for k, v := range shader.Uniforms {
+		s.Uniforms[k] = v
 	}
 
-	var geomSrc []byte
-	if geomFile != "" {
-		geomSrc, err = readFile(geomFile)
-		if err != nil {
-			return shader, err
-		}
+	if s.UniformInfos == nil {
+		s.UniformInfos = make(map[string]UniformInfo)
+	}
+	for k := range s.UniformInfos {
+		delete(s.UniformInfos, k)
+	}
+	for k, v := range shader.UniformInfos {
+		s.UniformInfos[k] = v
 	}
 
-	p, err := createProgram(vertSrc, fragSrc, geomSrc)
-	if err != nil {
-		return shader, err
+	if s.UniformBlocks == nil {
+		s.UniformBlocks = make(map[string]UniformBlock)
+	}
+	for k := range s.UniformBlocks {
+		delete(s.UniformBlocks, k)
+	}
+	for k, v := range shader.UniformBlocks {
+		s.UniformBlocks[k] = v
+	}
+
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]uint32)
+	}
+	for k := range s.Attributes {
+		delete(s.Attributes, k)
+	}
+	for k, v := range shader.Attributes {
+		s.Attributes[k] = v
 	}
-	shader = setupShader(p)
-	return shader, nil
+	return nil
+}
+
+// Files returns every file the shader was built from, including the files
+// pulled in by #include.
+func (s *Shader) Files() []string {
+	seen := make(map[string]bool)
+	files := []string{}
+	for _, f := range s.files {
+		if !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+	return files
 }
 
 func setupShader(program uint32) Shader {
@@ -78,15 +142,33 @@ func setupShader(program uint32) Shader {
 	)
 	gl.UseProgram(program)
 	uniforms := make(map[string]int32)
+	infos := make(map[string]UniformInfo)
 	attributes := map[string]uint32{} //make(map[string]uint32)
 
 	gl.GetProgramiv(program, gl.ACTIVE_UNIFORMS, &c)
 	for i = 0; i < uint32(c); i++ {
-		var buf [256]byte
-		gl.GetActiveUniform(program, i, 256, nil, nil, nil, &buf[0])
+		var (
+			buf   [256]byte
+			size  int32
+			xtype uint32
+		)
+		gl.GetActiveUniform(program, i, 256, nil, &size, &xtype, &buf[0])
 		loc := gl.GetUniformLocation(program, &buf[0])
+		if loc < 0 {
+			// members of uniform blocks have no location
+			continue
+		}
 		name := gl.GoStr(&buf[0])
 		uniforms[name] = loc
+		info := UniformInfo{Name: name, Location: loc, Type: xtype, Size: size}
+		infos[name] = info
+		// arrays are reported as "name[0]", make them reachable as "name" too
+		if strings.HasSuffix(name, "[0]") {
+			base := strings.TrimSuffix(name, "[0]")
+			info.Name = base
+			uniforms[base] = loc
+			infos[base] = info
+		}
 	}
 
 	gl.GetProgramiv(program, gl.ACTIVE_ATTRIBUTES, &c)
@@ -99,167 +181,33 @@ func setupShader(program uint32) Shader {
 	}
 
 	return Shader{
-		Program:    program,
-		Uniforms:   uniforms,
-		Attributes: attributes,
+		Program:       program,
+		Uniforms:      uniforms,
+		UniformInfos:  infos,
+		UniformBlocks: reflectUniformBlocks(program),
+		Attributes:    attributes,
 	}
 }
 
-func createProgram(v, f, g []byte) (uint32, error) {
-	var p, vertex, frag, geom uint32
-	use_geom := false
-
-	if val, err := compileShader(string(v)+"\x00", gl.VERTEX_SHADER); err != nil {
-		return 0, err
-	} else {
-		vertex = val
-		defer func(s uint32) { deleteShader(p, s) }(vertex)
-	}
-
-	if val, err := compileShader(string(f)+"\x00", gl.FRAGMENT_SHADER); err != nil {
-		return 0, err
-	} else {
-		frag = val
-		defer func(s uint32) { deleteShader(p, s) }(frag)
-	}
-
-	if len(g) > 0 {
-		if val, err := compileShader(string(g)+"\x00", gl.GEOMETRY_SHADER); err != nil {
-			return 0, err
-		} else {
-			geom = val
-			defer func(s uint32) { deleteShader(p, s) }(geom)
-		}
-	}
-
-	p, err := linkProgram(vertex, frag, geom, use_geom)
-	if err != nil {
-		return 0, err
-	}
-
-	return p, nil
-}
-
-func compileShader(source string, shaderType uint32) (uint32, error) {
-	shader := gl.CreateShader(shaderType)
-
-	csources, free := gl.Strs(source)
-	gl.ShaderSource(shader, 1, csources, nil)
-	free()
-	gl.CompileShader(shader)
-
-	var status int32
-	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
-	if status == gl.FALSE {
-		var logLength int32
-		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
-
-		log := strings.Repeat("\x00", int(logLength+1))
-		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
-
-		return 0, fmt.Errorf("failed to compile %v: %v", source, log)
-	}
-
-	return shader, nil
-}
-
-func deleteShader(p, s uint32) {
-	gl.DetachShader(p, s)
-	gl.DeleteShader(s)
-}
-
-func linkProgram(v, f, g uint32, use_geom bool) (uint32, error) {
-	program := gl.CreateProgram()
-	gl.AttachShader(program, v)
-	gl.AttachShader(program, f)
-	if use_geom {
-		gl.AttachShader(program, g)
-	}
-
-	gl.LinkProgram(program)
-	// check for program linking errors
-	var status int32
-	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
-	if status == gl.FALSE {
-		var logLength int32
-		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
-
-		log := strings.Repeat("\x00", int(logLength+1))
-		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
-
-		return 0, fmt.Errorf("failed to link program: %v", log)
-	}
-
-	return program, nil
-}
-
 type Shader struct {
-	Program    uint32
-	Uniforms   map[string]int32
-	Attributes map[string]uint32
+	Program       uint32
+	Uniforms      map[string]int32
+	UniformInfos  map[string]UniformInfo
+	UniformBlocks map[string]UniformBlock
+	Attributes    map[string]uint32
+	// Defines the shader was built with, for debugging.
+	Defines map[string]string
+
+	builder *ProgramBuilder
+	files   []string
 }
 
+// programGeneration counts the programs deleted by Shader.Delete. GL reuses
+// the names of deleted programs, caches keyed by program name are dropped
+// when it changes.
+var programGeneration uint64
+
 func (s *Shader) Delete() {
 	gl.DeleteProgram(s.Program)
-}
-
-type VertexArray struct {
-	Data          []float32
-	Indices       []uint32
-	Stride        int32
-	Normalized    bool
-	DrawMode      uint32
-	Attributes    AttributesMap
-	Vao, Vbo, Ebo uint32
-}
-
-func (v *VertexArray) Setup() {
-	gl.GenVertexArrays(1, &v.Vao)
-	fillVbo := true
-	// Vbo already set when VertexArray was instancied.
-	// This is a secondary structure using the same Vbo and vertex
-	// data but with a different shader and attributes
-	if v.Vbo == 0 {
-		gl.GenBuffers(1, &v.Vbo)
-	} else {
-		fillVbo = false
-	}
-	if len(v.Indices) > 0 {
-		gl.GenBuffers(1, &v.Ebo)
-	}
-
-	gl.BindVertexArray(v.Vao)
-
-	gl.BindBuffer(gl.ARRAY_BUFFER, v.Vbo)
-	if fillVbo {
-		gl.BufferData(gl.ARRAY_BUFFER, len(v.Data)*GL_FLOAT32_SIZE, gl.Ptr(v.Data), v.DrawMode)
-	}
-
-	if len(v.Indices) > 0 {
-		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, v.Ebo)
-		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(v.Indices)*GL_FLOAT32_SIZE, gl.Ptr(v.Indices), v.DrawMode)
-	}
-
-	for loc, ss := range v.Attributes {
-		gl.EnableVertexAttribArray(loc)
-		gl.VertexAttribPointer(loc, int32(ss[0]), gl.FLOAT, v.Normalized, v.Stride*GL_FLOAT32_SIZE, gl.PtrOffset(ss[1]*GL_FLOAT32_SIZE))
-	}
-	gl.BindVertexArray(0)
-}
-
-func (v *VertexArray) Delete() {
-	gl.DeleteVertexArrays(1, &v.Vao)
-	gl.DeleteBuffers(1, &v.Vbo)
-	if len(v.Indices) > 0 {
-		gl.DeleteBuffers(1, &v.Ebo)
-	}
-}
-
-type AttributesMap map[uint32][2]int //map attrib loc to size / offset
-
-func NewAttributesMap() AttributesMap {
-	return make(AttributesMap)
-}
-func (am AttributesMap) Add(k uint32, size, offset int) {
-	am[k] = [2]int{size, offset}
+	programGeneration++
 }
@@ -0,0 +1,208 @@
+package glutils
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is a single message from a shader compiler or linker info log.
+// Source is the source string number reported by the driver, which is an
+// index into ShaderSource.Files. Line and Column are 0 when the driver did not
+// report them.
+type Diagnostic struct {
+	Source   int
+	File     string
+	Line     int
+	Column   int
+	Severity string
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		if d.File == "" {
+			return d.Severity + ": " + d.Message
+		}
+		return fmt.Sprintf("%s: %s: %s", d.File, d.Severity, d.Message)
+	}
+	loc := d.File
+	if loc == "" {
+		loc = strconv.Itoa(d.Source)
+	}
+	loc += ":" + strconv.Itoa(d.Line)
+	if d.Column > 0 {
+		loc += ":" + strconv.Itoa(d.Column)
+	}
+	return fmt.Sprintf("%s: %s: %s", loc, d.Severity, d.Message)
+}
+
+// ShaderError is returned when a shader stage fails to compile or a program
+// fails to link. Stage is the GL shader type, or 0 for link errors.
+type ShaderError struct {
+	Stage       uint32
+	File        string
+	Log         string
+	Diagnostics []Diagnostic
+	Source      ShaderSource
+}
+
+func (e *ShaderError) Error() string {
+	action := "compile " + ShaderStageName(e.Stage) + " shader"
+	if e.Stage == 0 {
+		action = "link program"
+	}
+	if e.File != "" {
+		action += " " + e.File
+	}
+	errs := e.Errors()
+	if len(errs) == 0 {
+		return fmt.Sprintf("failed to %s: %s", action, strings.TrimSpace(e.Log))
+	}
+	msgs := make([]string, len(errs))
+	for i, d := range errs {
+		msgs[i] = d.String()
+	}
+	return fmt.Sprintf("failed to %s: %s", action, strings.Join(msgs, "; "))
+}
+
+// Errors returns the diagnostics with an error severity.
+func (e *ShaderError) Errors() []Diagnostic {
+	var errs []Diagnostic
+	for _, d := range e.Diagnostics {
+		if d.Severity == "error" {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
+
+// Pretty formats every diagnostic followed by the offending source line and
+// context lines around it, with a caret under the reported column.
+func (e *ShaderError) Pretty(context int) string {
+	var b strings.Builder
+	b.WriteString(e.Error())
+	b.WriteString("\n")
+	for _, d := range e.Diagnostics {
+		b.WriteString(d.String())
+		b.WriteString("\n")
+		if d.Line == 0 {
+			continue
+		}
+		for l := d.Line - context; l <= d.Line+context; l++ {
+			text, ok := e.Source.Line(d.Source, l)
+			if !ok {
+				continue
+			}
+			marker := " "
+			if l == d.Line {
+				marker = ">"
+			}
+			fmt.Fprintf(&b, "%s %5d | %s\n", marker, l, text)
+			if l == d.Line && d.Column > 0 {
+				fmt.Fprintf(&b, "        | %s^\n", strings.Repeat(" ", d.Column-1))
+			}
+		}
+	}
+	return b.String()
+}
+
+var (
+	// NVIDIA: 0(12) : error C0000: syntax error, unexpected ...
+	nvidiaLog = regexp.MustCompile(`^(\d+)\((\d+)\)\s*:\s*(fatal error|internal error|error|warning)\s*\w*\s*:\s*(.*)$`)
+	// Mesa: 0:12(5): error: `x' undeclared
+	mesaLog = regexp.MustCompile(`^(\d+):(\d+)\((\d+)\)\s*:\s*(preprocessor error|error|warning)\s*:\s*(.*)$`)
+	// AMD, Apple and Intel: ERROR: 0:12: 'x' : undeclared identifier
+	amdLog = regexp.MustCompile(`^(ERROR|WARNING|INFO)\s*:\s*(\d+):(\d+)\s*:\s*(.*)$`)
+	// Messages without a location: ERROR: ..., error: ..., warning: ...
+	plainLog = regexp.MustCompile(`(?i)^(fatal error|error|warning|info)\s*:\s*(.*)$`)
+	// Summary lines that don't carry any information.
+	summaryLog = regexp.MustCompile(`(?i)^(ERROR|WARNING):\s*\d+ compilation errors?\.`)
+	// NVIDIA link log headers: "Vertex info" underlined with dashes.
+	headerLog = regexp.MustCompile(`(?i)^((vertex|fragment|geometry|tessellation control|tessellation evaluation|compute) info|-+)$`)
+)
+
+// ParseInfoLog extracts diagnostics from a shader or program info log in the
+// formats produced by the NVIDIA, Mesa, AMD and Apple drivers. Lines that are
+// not recognized are returned as info without a location.
+func ParseInfoLog(log string) []Diagnostic {
+	var diags []Diagnostic
+	scanner := bufio.NewScanner(strings.NewReader(strings.TrimRight(log, "\x00")))
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.Trim(scanner.Text(), "\x00"))
+		if line == "" || summaryLog.MatchString(line) || headerLog.MatchString(line) {
+			continue
+		}
+		if m := mesaLog.FindStringSubmatch(line); m != nil {
+			diags = append(diags, Diagnostic{
+				Source:   atoi(m[1]),
+				Line:     atoi(m[2]),
+				Column:   atoi(m[3]),
+				Severity: severity(m[4]),
+				Message:  m[5],
+			})
+			continue
+		}
+		if m := nvidiaLog.FindStringSubmatch(line); m != nil {
+			diags = append(diags, Diagnostic{
+				Source:   atoi(m[1]),
+				Line:     atoi(m[2]),
+				Severity: severity(m[3]),
+				Message:  m[4],
+			})
+			continue
+		}
+		if m := amdLog.FindStringSubmatch(line); m != nil {
+			diags = append(diags, Diagnostic{
+				Source:   atoi(m[2]),
+				Line:     atoi(m[3]),
+				Severity: severity(m[1]),
+				Message:  m[4],
+			})
+			continue
+		}
+		if m := plainLog.FindStringSubmatch(line); m != nil {
+			diags = append(diags, Diagnostic{
+				Severity: severity(m[1]),
+				Message:  m[2],
+			})
+			continue
+		}
+		diags = append(diags, Diagnostic{Severity: "info", Message: line})
+	}
+	return diags
+}
+
+func severity(s string) string {
+	s = strings.ToLower(s)
+	switch {
+	case strings.Contains(s, "error"):
+		return "error"
+	case strings.Contains(s, "warning"):
+		return "warning"
+	}
+	return "info"
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
+
+// newShaderError builds a ShaderError from a driver info log, resolving the
+// source string numbers of the diagnostics to file names.
+func newShaderError(stage uint32, src ShaderSource, log string) *ShaderError {
+	diags := ParseInfoLog(log)
+	for i := range diags {
+		diags[i].File = src.FileName(diags[i].Source)
+	}
+	return &ShaderError{
+		Stage:       stage,
+		File:        src.File,
+		Log:         strings.TrimRight(log, "\x00"),
+		Diagnostics: diags,
+		Source:      src,
+	}
+}
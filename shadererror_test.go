@@ -0,0 +1,121 @@
+package glutils
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+func TestParseInfoLog(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want []Diagnostic
+	}{
+		{
+			name: "nvidia compile",
+			log: "0(5) : error C0000: syntax error, unexpected '}', expecting ',' or ';' at token \"}\"\n" +
+				"0(7) : warning C7533: global variable gl_FragColor is deprecated after version 120\n\x00",
+			want: []Diagnostic{
+				{Source: 0, Line: 5, Severity: "error", Message: "syntax error, unexpected '}', expecting ',' or ';' at token \"}\""},
+				{Source: 0, Line: 7, Severity: "warning", Message: "global variable gl_FragColor is deprecated after version 120"},
+			},
+		},
+		{
+			name: "nvidia link",
+			log: "Vertex info\n-----------\n0(3) : error C5145: must write to gl_Position\n\n" +
+				"Fragment info\n-------------\n0(10) : error C1008: undefined variable \"color\"\n",
+			want: []Diagnostic{
+				{Source: 0, Line: 3, Severity: "error", Message: "must write to gl_Position"},
+				{Source: 0, Line: 10, Severity: "error", Message: "undefined variable \"color\""},
+			},
+		},
+		{
+			name: "mesa",
+			log: "0:5(1): error: syntax error, unexpected '}', expecting ',' or ';'\n" +
+				"1:12(14): warning: `x' used uninitialized\n" +
+				"0:3(10): preprocessor error: Unterminated #if\n",
+			want: []Diagnostic{
+				{Source: 0, Line: 5, Column: 1, Severity: "error", Message: "syntax error, unexpected '}', expecting ',' or ';'"},
+				{Source: 1, Line: 12, Column: 14, Severity: "warning", Message: "`x' used uninitialized"},
+				{Source: 0, Line: 3, Column: 10, Severity: "error", Message: "Unterminated #if"},
+			},
+		},
+		{
+			name: "mesa link",
+			log:  "error: fragment shader output `color' is not written\n",
+			want: []Diagnostic{
+				{Severity: "error", Message: "fragment shader output `color' is not written"},
+			},
+		},
+		{
+			name: "amd",
+			log:  "ERROR: 0:5: '}' : syntax error syntax error\nERROR: 1 compilation errors.  No code generated.\n\n",
+			want: []Diagnostic{
+				{Source: 0, Line: 5, Severity: "error", Message: "'}' : syntax error syntax error"},
+			},
+		},
+		{
+			name: "apple",
+			log: "ERROR: 0:3: Use of undeclared identifier 'foo'\n" +
+				"WARNING: 0:8: Overflow in implicit constant conversion, minimum range for lowp float is (-2,2)\n",
+			want: []Diagnostic{
+				{Source: 0, Line: 3, Severity: "error", Message: "Use of undeclared identifier 'foo'"},
+				{Source: 0, Line: 8, Severity: "warning", Message: "Overflow in implicit constant conversion, minimum range for lowp float is (-2,2)"},
+			},
+		},
+		{
+			name: "unrecognized",
+			log:  "Vertex shader(s) failed to link.\n",
+			want: []Diagnostic{
+				{Severity: "info", Message: "Vertex shader(s) failed to link."},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := ParseInfoLog(tt.log)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got\n%#v\nwant\n%#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShaderErrorUnrecognizedLog(t *testing.T) {
+	e := newShaderError(0, ShaderSource{}, "Vertex info\n-----------\nlink failed\x00")
+	if len(e.Errors()) != 0 {
+		t.Fatalf("unexpected errors %v", e.Errors())
+	}
+	if want := "failed to link program: Vertex info\n-----------\nlink failed"; e.Error() != want {
+		t.Errorf("got %q, want %q", e.Error(), want)
+	}
+}
+
+func TestShaderErrorPretty(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.frag":  {Data: []byte("#version 410 core\n#include \"light.glsl\"\nout vec4 color;\nvoid main() {\n\tcolor = shade();\n}\n")},
+		"light.glsl": {Data: []byte("vec3 shade() {\n\treturn vec3(x);\n}\n")},
+	}
+	src, err := NewPreprocessor().WithFS(fsys).Process("main.frag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second line of the include and the line after the #include
+	e := newShaderError(gl.FRAGMENT_SHADER, src, "0:5(10): error: type mismatch\n1:2(14): error: `x' undeclared\nwarning: unused\n")
+	want := "failed to compile fragment shader main.frag: main.frag:5:10: error: type mismatch; light.glsl:2:14: error: `x' undeclared\n" +
+		"main.frag:5:10: error: type mismatch\n" +
+		"      4 | void main() {\n" +
+		">     5 | \tcolor = shade();\n" +
+		"        |          ^\n" +
+		"      6 | }\n" +
+		"light.glsl:2:14: error: `x' undeclared\n" +
+		"      1 | vec3 shade() {\n" +
+		">     2 | \treturn vec3(x);\n" +
+		"        |              ^\n" +
+		"      3 | }\n" +
+		"main.frag: warning: unused\n"
+	if got := e.Pretty(1); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package glutils
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// MaxBoneInfluences is the number of bones a vertex can be attached to.
+const MaxBoneInfluences = 4
+
+// Bone is a joint of a skeleton, named after the node animating it.
+type Bone struct {
+	Name string
+	// InverseBind transforms model space vertices to the bone space of the
+	// bind pose.
+	InverseBind mgl32.Mat4
+}
+
+// Skeleton is the bones vertices of a model are attached to, Vertex.BoneIDs
+// are indices in Bones.
+type Skeleton struct {
+	Bones []Bone
+	index map[string]int
+}
+
+// AddBone appends a bone and returns its index, or returns the index of the
+// bone named name when there is one.
+func (s *Skeleton) AddBone(name string, inverseBind mgl32.Mat4) int {
+	if i, ok := s.BoneIndex(name); ok {
+		return i
+	}
+	s.Bones = append(s.Bones, Bone{Name: name, InverseBind: inverseBind})
+	s.index[name] = len(s.Bones) - 1
+	return len(s.Bones) - 1
+}
+
+// BoneIndex returns the index of the bone named name.
+func (s *Skeleton) BoneIndex(name string) (int, bool) {
+	if s.index == nil {
+		// skeletons decoded from gob files have no index
+		s.index = make(map[string]int, len(s.Bones))
+		for i, b := range s.Bones {
+			s.index[b.Name] = i
+		}
+	}
+	i, ok := s.index[name]
+	return i, ok
+}
+
+// AddBoneWeight attaches v to the bone id with weight w. Past
+// MaxBoneInfluences bones, the weakest influence is replaced.
+func (v *Vertex) AddBoneWeight(id int32, w float32) {
+	weakest := 0
+	for i := 0; i < MaxBoneInfluences; i++ {
+		if v.Weights[i] < v.Weights[weakest] {
+			weakest = i
+		}
+	}
+	if w > v.Weights[weakest] {
+		v.BoneIDs[weakest], v.Weights[weakest] = id, w
+	}
+}
+
+// NormalizeWeights scales the bone weights of v to sum to 1.
+func (v *Vertex) NormalizeWeights() {
+	var sum float32
+	for _, w := range v.Weights {
+		sum += w
+	}
+	if sum == 0 {
+		return
+	}
+	for i := range v.Weights {
+		v.Weights[i] /= sum
+	}
+}
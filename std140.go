@@ -0,0 +1,318 @@
+package glutils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Std140Field is the position of a leaf member of a uniform block as laid out
+// by the std140 rules. Name follows the naming of glGetActiveUniformName:
+// "lights[1].color", "weights[0]", "material.shininess".
+type Std140Field struct {
+	Name         string
+	Offset       int
+	Size         int
+	ArrayStride  int
+	MatrixStride int
+}
+
+// EncodeStd140 packs a struct into a byte slice using the std140 layout,
+// ready to be uploaded to a uniform buffer. Supported field types are float32,
+// int32, uint32, bool, mgl32.Vec2/Vec3/Vec4, mgl32.Mat2/Mat3/Mat4, nested
+// structs and arrays or slices of those. Go arrays of float32 are GLSL float
+// arrays, use the mgl32 types for vectors.
+// The GLSL name of a field is its `std140:"name"` tag, or the field name with
+// the first letter lower cased. Fields tagged `std140:"-"` are skipped.
+func EncodeStd140(v interface{}) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("std140: expected a struct, got %s", rv.Type())
+	}
+	e := &std140Encoder{}
+	if err := e.value(rv, ""); err != nil {
+		return nil, err
+	}
+	// the block size is rounded up like a struct member would be
+	e.pad(roundUp(len(e.buf), 16))
+	return e.buf, nil
+}
+
+// Std140Layout returns the std140 offsets of every leaf member of a struct and
+// the total size of the block.
+func Std140Layout(v interface{}) ([]Std140Field, int, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, 0, fmt.Errorf("std140: expected a struct, got %s", rv.Type())
+	}
+	e := &std140Encoder{layout: true}
+	if err := e.value(rv, ""); err != nil {
+		return nil, 0, err
+	}
+	return e.fields, roundUp(len(e.buf), 16), nil
+}
+
+var (
+	vec2Type = reflect.TypeOf(mgl32.Vec2{})
+	vec3Type = reflect.TypeOf(mgl32.Vec3{})
+	vec4Type = reflect.TypeOf(mgl32.Vec4{})
+	mat2Type = reflect.TypeOf(mgl32.Mat2{})
+	mat3Type = reflect.TypeOf(mgl32.Mat3{})
+	mat4Type = reflect.TypeOf(mgl32.Mat4{})
+)
+
+type std140Encoder struct {
+	buf    []byte
+	fields []Std140Field
+	layout bool
+}
+
+func (e *std140Encoder) pad(n int) {
+	for len(e.buf) < n {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *std140Encoder) align(a int) {
+	e.pad(roundUp(len(e.buf), a))
+}
+
+func (e *std140Encoder) put32(bits uint32) {
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, bits)
+}
+
+func (e *std140Encoder) field(name string, offset, arrayStride, matrixStride int) {
+	if e.layout {
+		e.fields = append(e.fields, Std140Field{
+			Name:         name,
+			Offset:       offset,
+			Size:         len(e.buf) - offset,
+			ArrayStride:  arrayStride,
+			MatrixStride: matrixStride,
+		})
+	}
+}
+
+// value writes v at the current position, aligned per std140.
+func (e *std140Encoder) value(v reflect.Value, name string) error {
+	t := v.Type()
+	switch t {
+	case vec2Type, vec3Type, vec4Type:
+		e.align(std140Align(t))
+		start := len(e.buf)
+		for i := 0; i < v.Len(); i++ {
+			e.put32(math.Float32bits(float32(v.Index(i).Float())))
+		}
+		e.field(name, start, 0, 0)
+		return nil
+	case mat2Type, mat3Type, mat4Type:
+		// a matN is an array of N column vectors, each padded to a vec4
+		n := int(math.Sqrt(float64(v.Len())))
+		e.align(16)
+		start := len(e.buf)
+		for c := 0; c < n; c++ {
+			col := len(e.buf)
+			for r := 0; r < n; r++ {
+				e.put32(math.Float32bits(float32(v.Index(c*n + r).Float())))
+			}
+			e.pad(col + 16)
+		}
+		e.field(name, start, 0, 16)
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Float32, reflect.Int32, reflect.Uint32, reflect.Bool:
+		e.align(4)
+		start := len(e.buf)
+		switch t.Kind() {
+		case reflect.Float32:
+			e.put32(math.Float32bits(float32(v.Float())))
+		case reflect.Int32:
+			e.put32(uint32(int32(v.Int())))
+		case reflect.Uint32:
+			e.put32(uint32(v.Uint()))
+		case reflect.Bool:
+			var b uint32
+			if v.Bool() {
+				b = 1
+			}
+			e.put32(b)
+		}
+		e.field(name, start, 0, 0)
+	case reflect.Array, reflect.Slice:
+		return e.array(v, name)
+	case reflect.Struct:
+		e.align(std140Align(t))
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			fname := std140Name(f)
+			if fname == "-" {
+				continue
+			}
+			if name != "" {
+				fname = name + "." + fname
+			}
+			if err := e.value(v.Field(i), fname); err != nil {
+				return err
+			}
+		}
+		// a struct is padded to a multiple of its alignment
+		e.align(std140Align(t))
+	default:
+		return fmt.Errorf("std140: unsupported type %s for %q", t, name)
+	}
+	return nil
+}
+
+func (e *std140Encoder) array(v reflect.Value, name string) error {
+	elem := v.Type().Elem()
+	if hasSlice(elem) {
+		return fmt.Errorf("std140: %q: array elements can't contain slices", name)
+	}
+	// every array element is aligned like a vec4
+	stride := roundUp(std140Size(elem), 16)
+	e.align(16)
+	start := len(e.buf)
+	if isStd140Leaf(elem) {
+		for i := 0; i < v.Len(); i++ {
+			el := len(e.buf)
+			saved := e.layout
+			e.layout = false
+			if err := e.value(v.Index(i), name); err != nil {
+				return err
+			}
+			e.layout = saved
+			e.pad(el + stride)
+		}
+		matrixStride := 0
+		if elem == mat2Type || elem == mat3Type || elem == mat4Type {
+			matrixStride = 16
+		}
+		e.field(name+"[0]", start, stride, matrixStride)
+		return nil
+	}
+	for i := 0; i < v.Len(); i++ {
+		el := len(e.buf)
+		if err := e.value(v.Index(i), fmt.Sprintf("%s[%d]", name, i)); err != nil {
+			return err
+		}
+		e.pad(el + stride)
+	}
+	return nil
+}
+
+// isStd140Leaf reports whether t is reported by GL as a single member, as
+// opposed to structs whose fields are reported one by one.
+func isStd140Leaf(t reflect.Type) bool {
+	return t.Kind() != reflect.Struct && t.Kind() != reflect.Array && t.Kind() != reflect.Slice ||
+		t == vec2Type || t == vec3Type || t == vec4Type ||
+		t == mat2Type || t == mat3Type || t == mat4Type
+}
+
+func hasSlice(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice:
+		return true
+	case reflect.Array:
+		return hasSlice(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasSlice(t.Field(i).Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func std140Align(t reflect.Type) int {
+	switch t {
+	case vec2Type:
+		return 8
+	case vec3Type, vec4Type, mat2Type, mat3Type, mat4Type:
+		return 16
+	}
+	switch t.Kind() {
+	case reflect.Array, reflect.Slice:
+		return 16
+	case reflect.Struct:
+		a := 0
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath == "" && std140Name(t.Field(i)) != "-" {
+				if fa := std140Align(t.Field(i).Type); fa > a {
+					a = fa
+				}
+			}
+		}
+		return roundUp(a, 16)
+	}
+	return 4
+}
+
+// std140Size returns the size of t without the trailing padding an array
+// element receives.
+func std140Size(t reflect.Type) int {
+	switch t {
+	case vec2Type:
+		return 8
+	case vec3Type:
+		return 12
+	case vec4Type:
+		return 16
+	case mat2Type:
+		return 32
+	case mat3Type:
+		return 48
+	case mat4Type:
+		return 64
+	}
+	switch t.Kind() {
+	case reflect.Array:
+		return t.Len() * roundUp(std140Size(t.Elem()), 16)
+	case reflect.Struct:
+		size := 0
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || std140Name(f) == "-" {
+				continue
+			}
+			size = roundUp(size, std140Align(f.Type)) + std140Size(f.Type)
+			if !isStd140Leaf(f.Type) {
+				size = roundUp(size, 16)
+			}
+		}
+		return roundUp(size, std140Align(t))
+	}
+	return 4
+}
+
+func std140Name(f reflect.StructField) string {
+	if tag := f.Tag.Get("std140"); tag != "" {
+		return tag
+	}
+	r, n := utf8.DecodeRuneInString(f.Name)
+	return string(unicode.ToLower(r)) + f.Name[n:]
+}
+
+func roundUp(n, a int) int {
+	if a == 0 {
+		return n
+	}
+	return (n + a - 1) / a * a
+}
+
+// std140MemberName strips the block name GL prefixes to members of blocks
+// with an instance name.
+func std140MemberName(block, member string) string {
+	return strings.TrimPrefix(member, block+".")
+}
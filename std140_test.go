@@ -0,0 +1,102 @@
+package glutils
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type std140TestLight struct {
+	Position  mgl32.Vec3
+	Intensity float32
+	Color     mgl32.Vec3
+}
+
+type std140TestBlock struct {
+	Scale   float32
+	Offset  mgl32.Vec2
+	Normal  mgl32.Mat3
+	Weights [3]float32
+	Lights  [2]std140TestLight
+	Flag    bool
+	Model   mgl32.Mat4
+	Skipped float32 `std140:"-"`
+}
+
+func TestStd140Layout(t *testing.T) {
+	fields, size, err := Std140Layout(std140TestBlock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Std140Field{
+		{Name: "scale", Offset: 0, Size: 4},
+		{Name: "offset", Offset: 8, Size: 8},
+		// mat3 columns are padded to vec4
+		{Name: "normal", Offset: 16, Size: 48, MatrixStride: 16},
+		// float array elements are padded to vec4
+		{Name: "weights[0]", Offset: 64, Size: 48, ArrayStride: 16},
+		// structs are aligned and sized to vec4
+		{Name: "lights[0].position", Offset: 112, Size: 12},
+		{Name: "lights[0].intensity", Offset: 124, Size: 4},
+		{Name: "lights[0].color", Offset: 128, Size: 12},
+		{Name: "lights[1].position", Offset: 144, Size: 12},
+		{Name: "lights[1].intensity", Offset: 156, Size: 4},
+		{Name: "lights[1].color", Offset: 160, Size: 12},
+		{Name: "flag", Offset: 176, Size: 4},
+		{Name: "model", Offset: 192, Size: 64, MatrixStride: 16},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", fields, want)
+	}
+	if size != 256 {
+		t.Errorf("block size %d, want 256", size)
+	}
+}
+
+func TestEncodeStd140(t *testing.T) {
+	b, err := EncodeStd140(&std140TestBlock{
+		Scale:   1,
+		Normal:  mgl32.Ident3(),
+		Weights: [3]float32{1, 2, 3},
+		Lights:  [2]std140TestLight{{Intensity: 5}, {Color: mgl32.Vec3{0, 0, 7}}},
+		Flag:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 256 {
+		t.Fatalf("encoded %d bytes, want 256", len(b))
+	}
+	float := func(off int) float32 {
+		return math.Float32frombits(binary.LittleEndian.Uint32(b[off:]))
+	}
+	for _, c := range []struct {
+		offset int
+		want   float32
+	}{
+		{0, 1},
+		{16, 1}, {20, 0}, {36, 1}, {52, 0}, {56, 1}, // identity mat3 with padded columns
+		{64, 1}, {80, 2}, {96, 3},
+		{124, 5},
+		{168, 7},
+	} {
+		if got := float(c.offset); got != c.want {
+			t.Errorf("float at %d is %v, want %v", c.offset, got, c.want)
+		}
+	}
+	if got := binary.LittleEndian.Uint32(b[176:]); got != 1 {
+		t.Errorf("bool encoded as %d, want 1", got)
+	}
+}
+
+func TestStd140Errors(t *testing.T) {
+	if _, err := EncodeStd140(1.5); err == nil {
+		t.Error("expected an error for a non struct value")
+	}
+	if _, _, err := Std140Layout(struct{ C complex64 }{}); err == nil {
+		t.Error("expected an error for an unsupported field type")
+	}
+}
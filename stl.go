@@ -0,0 +1,203 @@
+package glutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// DefaultSTLSmoothingAngle is the smoothing angle, in radians, of STL models
+// loaded by Model.
+var DefaultSTLSmoothingAngle = mgl32.DegToRad(30)
+
+type stlTriangle struct {
+	normal   mgl32.Vec3
+	vertices [3]mgl32.Vec3
+}
+
+// ParseSTL reads an ASCII or binary STL file into a mesh. Corners of
+// triangles at the same position are welded into a single vertex, whose
+// normal is smoothed across the triangles meeting there at less than
+// smoothingAngle radians; sharper edges keep distinct vertices. A smoothing
+// angle of 0 welds only the corners of coplanar triangles.
+func ParseSTL(r io.Reader, smoothingAngle float32) (Mesh, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Mesh{}, err
+	}
+	var triangles []stlTriangle
+	if isBinarySTL(data) {
+		triangles, err = parseBinarySTL(data)
+	} else {
+		triangles, err = parseASCIISTL(data)
+	}
+	if err != nil {
+		return Mesh{}, err
+	}
+	vertices, indices := weldSTL(triangles, smoothingAngle)
+	return NewMesh(vertices, indices, nil), nil
+}
+
+// isBinarySTL tells binary files from ASCII ones. The "solid" prefix of ASCII
+// files is not enough, some exporters start binary headers with it too.
+func isBinarySTL(data []byte) bool {
+	if len(data) >= 84 {
+		n := binary.LittleEndian.Uint32(data[80:])
+		if uint64(len(data)) == 84+uint64(n)*50 {
+			return true
+		}
+	}
+	return !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("solid"))
+}
+
+func parseBinarySTL(data []byte) ([]stlTriangle, error) {
+	if len(data) < 84 {
+		return nil, fmt.Errorf("stl: truncated header")
+	}
+	n := int(binary.LittleEndian.Uint32(data[80:]))
+	if len(data) < 84+n*50 {
+		return nil, fmt.Errorf("stl: %d triangles expected, file is %d bytes", n, len(data))
+	}
+	vec := func(b []byte) mgl32.Vec3 {
+		return mgl32.Vec3{
+			math.Float32frombits(binary.LittleEndian.Uint32(b)),
+			math.Float32frombits(binary.LittleEndian.Uint32(b[4:])),
+			math.Float32frombits(binary.LittleEndian.Uint32(b[8:])),
+		}
+	}
+	triangles := make([]stlTriangle, n)
+	for i := range triangles {
+		b := data[84+i*50:]
+		triangles[i].normal = vec(b)
+		for j := 0; j < 3; j++ {
+			triangles[i].vertices[j] = vec(b[12+j*12:])
+		}
+	}
+	return triangles, nil
+}
+
+func parseASCIISTL(data []byte) ([]stlTriangle, error) {
+	var (
+		triangles []stlTriangle
+		cur       stlTriangle
+		corner    int
+	)
+	for n, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var err error
+		switch fields[0] {
+		case "facet":
+			cur, corner = stlTriangle{}, 0
+			if len(fields) == 5 && fields[1] == "normal" {
+				cur.normal, err = parseSTLVec3(fields[2:])
+			}
+		case "vertex":
+			if corner >= 3 {
+				return nil, fmt.Errorf("stl line %d: facet has more than 3 vertices", n+1)
+			}
+			cur.vertices[corner], err = parseSTLVec3(fields[1:])
+			corner++
+		case "endfacet":
+			if corner != 3 {
+				return nil, fmt.Errorf("stl line %d: facet has %d vertices", n+1, corner)
+			}
+			triangles = append(triangles, cur)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("stl line %d: %v", n+1, err)
+		}
+	}
+	return triangles, nil
+}
+
+func parseSTLVec3(fields []string) (mgl32.Vec3, error) {
+	var v mgl32.Vec3
+	if len(fields) != 3 {
+		return v, fmt.Errorf("expected 3 coordinates, got %d", len(fields))
+	}
+	for i, f := range fields {
+		x, err := strconv.ParseFloat(f, 32)
+		if err != nil {
+			return v, err
+		}
+		v[i] = float32(x)
+	}
+	return v, nil
+}
+
+// weldSTL merges the corners of the triangles into indexed vertices.
+func weldSTL(triangles []stlTriangle, smoothingAngle float32) ([]Vertex, []uint32) {
+	// the facet normals of files are often missing or wrong, use the
+	// winding, unnormalized so that larger triangles weigh more
+	normals := make([]mgl32.Vec3, len(triangles))
+	units := make([]mgl32.Vec3, len(triangles))
+	corners := make(map[mgl32.Vec3][]int)
+	for i, t := range triangles {
+		normals[i] = t.vertices[1].Sub(t.vertices[0]).Cross(t.vertices[2].Sub(t.vertices[0]))
+		if normals[i].Len() > 0 {
+			units[i] = normals[i].Normalize()
+		} else if t.normal.Len() > 0 {
+			units[i] = t.normal.Normalize()
+		}
+		for _, p := range t.vertices {
+			p = weldKey(p)
+			if c := corners[p]; len(c) == 0 || c[len(c)-1] != i {
+				corners[p] = append(c, i)
+			}
+		}
+	}
+
+	cos := float32(math.Cos(float64(smoothingAngle)))
+	type vertexKey struct {
+		position, normal mgl32.Vec3
+	}
+	index := make(map[vertexKey]uint32)
+	var (
+		vertices []Vertex
+		indices  []uint32
+	)
+	for i, t := range triangles {
+		for _, p := range t.vertices {
+			var n mgl32.Vec3
+			for _, j := range corners[weldKey(p)] {
+				if units[i].Dot(units[j]) >= cos-1e-6 {
+					n = n.Add(normals[j])
+				}
+			}
+			if n.Len() > 0 {
+				n = n.Normalize()
+			} else {
+				n = units[i]
+			}
+			key := vertexKey{weldKey(p), weldKey(n)}
+			idx, ok := index[key]
+			if !ok {
+				idx = uint32(len(vertices))
+				vertices = append(vertices, Vertex{Position: p, Normal: n})
+				index[key] = idx
+			}
+			indices = append(indices, idx)
+		}
+	}
+	return vertices, indices
+}
+
+// weldKey makes -0 and 0 the same map key.
+func weldKey(v mgl32.Vec3) mgl32.Vec3 {
+	for i := range v {
+		if v[i] == 0 {
+			v[i] = 0
+		}
+	}
+	return v
+}
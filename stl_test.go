@@ -0,0 +1,116 @@
+package glutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// binarySTL writes triangles with a header starting like an ASCII file, as
+// some exporters do.
+func binarySTL(triangles [][3]mgl32.Vec3) []byte {
+	var b bytes.Buffer
+	var header [80]byte
+	copy(header[:], "solid binary file")
+	b.Write(header[:])
+	binary.Write(&b, binary.LittleEndian, uint32(len(triangles)))
+	for _, t := range triangles {
+		binary.Write(&b, binary.LittleEndian, mgl32.Vec3{})
+		binary.Write(&b, binary.LittleEndian, t)
+		b.Write([]byte{0, 0})
+	}
+	return b.Bytes()
+}
+
+// fold is two triangles sharing the edge from the origin to +y, one in the
+// z = 0 plane and one in the x = 0 plane, 90 degrees apart.
+var fold = [][3]mgl32.Vec3{
+	{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+	{{0, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+}
+
+func TestParseSTLBinarySolidHeader(t *testing.T) {
+	m, err := ParseSTL(bytes.NewReader(binarySTL(fold)), DefaultSTLSmoothingAngle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Indices) != 6 {
+		t.Fatalf("got %d triangles, want 2", len(m.Indices)/3)
+	}
+	if p := m.Vertices[m.Indices[1]].Position; p != (mgl32.Vec3{1, 0, 0}) {
+		t.Errorf("second corner at %v", p)
+	}
+}
+
+func TestParseSTLSmoothingAngle(t *testing.T) {
+	for _, c := range []struct {
+		angle    float32
+		vertices int
+	}{
+		// the shared edge keeps a vertex per side
+		{DefaultSTLSmoothingAngle, 6},
+		{mgl32.DegToRad(89), 6},
+		// the shared edge is welded and its normals smoothed
+		{mgl32.DegToRad(91), 4},
+	} {
+		m, err := ParseSTL(bytes.NewReader(binarySTL(fold)), c.angle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Vertices) != c.vertices {
+			t.Errorf("angle %v: got %d vertices, want %d", mgl32.RadToDeg(c.angle), len(m.Vertices), c.vertices)
+		}
+		for _, v := range m.Vertices {
+			shared := v.Position == (mgl32.Vec3{}) || v.Position == (mgl32.Vec3{0, 1, 0})
+			if shared && c.vertices == 4 {
+				if want := (mgl32.Vec3{1, 0, 1}).Normalize(); !v.Normal.ApproxEqual(want) {
+					t.Errorf("angle %v: welded normal at %v is %v, want %v", mgl32.RadToDeg(c.angle), v.Position, v.Normal, want)
+				}
+			} else if !v.Normal.ApproxEqual(mgl32.Vec3{0, 0, 1}) && !v.Normal.ApproxEqual(mgl32.Vec3{1, 0, 0}) {
+				t.Errorf("angle %v: normal at %v is %v, want a face normal", mgl32.RadToDeg(c.angle), v.Position, v.Normal)
+			}
+		}
+	}
+}
+
+func TestParseSTLCoplanar(t *testing.T) {
+	quad := `solid quad
+facet normal 0 0 1
+ outer loop
+  vertex 0 0 0
+  vertex 1 0 0
+  vertex 1 1 0
+ endloop
+endfacet
+facet normal 0 0 1
+ outer loop
+  vertex 0 0 0
+  vertex 1 1 0
+  vertex 0 1 0
+ endloop
+endfacet
+endsolid quad
+`
+	m, err := ParseSTL(strings.NewReader(quad), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Vertices) != 4 || len(m.Indices) != 6 {
+		t.Errorf("got %d vertices and %d indices, want 4 and 6", len(m.Vertices), len(m.Indices))
+	}
+}
+
+func TestParseSTLErrors(t *testing.T) {
+	for _, stl := range []string{
+		"solid x\nfacet normal 0 0 1\nouter loop\nvertex 0 0 0\nvertex 1 0 0\nendloop\nendfacet\n",
+		"solid x\nfacet normal 0 0 1\nouter loop\nvertex 0 0 a\n",
+		"\x00\x00\x00",
+	} {
+		if _, err := ParseSTL(strings.NewReader(stl), 0); err == nil {
+			t.Errorf("no error for %q", stl)
+		}
+	}
+}
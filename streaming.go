@@ -0,0 +1,162 @@
+package glutils
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// StreamingBuffer is a buffer object used as a ring of per-frame regions, for
+// geometry rewritten every frame such as particles or debug lines.
+// Write copies data to the next free region without synchronizing with the
+// GPU; EndFrame fences the regions written during the frame and Write waits on
+// the fence of a region only when the ring wraps around onto it.
+//
+// The buffer can be the Vbo of a VertexArray: draw the data written at offset
+// with DrawRange(offset/stride, count).
+type StreamingBuffer struct {
+	Target uint32
+	Buffer uint32
+	Size   int
+
+	head    int
+	pending []streamSpan
+	fences  []streamFence
+}
+
+type streamSpan struct {
+	start, end int
+}
+
+func (s streamSpan) overlaps(start, end int) bool {
+	return s.start < end && start < s.end
+}
+
+type streamFence struct {
+	sync  uintptr
+	spans []streamSpan
+}
+
+// NewStreamingBuffer allocates a ring of size bytes bound to target, e.g.
+// gl.ARRAY_BUFFER. Size it for about three frames of data.
+func NewStreamingBuffer(target uint32, size int) *StreamingBuffer {
+	s := &StreamingBuffer{Target: target, Size: size}
+	gl.GenBuffers(1, &s.Buffer)
+	gl.BindBuffer(target, s.Buffer)
+	gl.BufferData(target, size, nil, gl.STREAM_DRAW)
+	gl.BindBuffer(target, 0)
+	return s
+}
+
+// Bind binds the buffer to its target.
+func (s *StreamingBuffer) Bind() {
+	gl.BindBuffer(s.Target, s.Buffer)
+}
+
+// Alloc reserves size bytes aligned to align and returns their offset,
+// waiting for the GPU to be done with them if needed.
+func (s *StreamingBuffer) Alloc(size, align int) (int, error) {
+	if size > s.Size {
+		return 0, fmt.Errorf("streaming buffer: %d bytes requested from a %d bytes buffer", size, s.Size)
+	}
+	offset := roundUp(s.head, align)
+	if offset+size > s.Size {
+		offset = 0
+	}
+	end := offset + size
+	for _, span := range s.pending {
+		if span.overlaps(offset, end) {
+			return 0, fmt.Errorf("streaming buffer: frame data exceeds the %d bytes buffer", s.Size)
+		}
+	}
+	if err := s.wait(offset, end); err != nil {
+		return 0, err
+	}
+	s.head = end
+	if n := len(s.pending); n > 0 && s.pending[n-1].end <= offset && roundUp(s.pending[n-1].end, align) == offset {
+		s.pending[n-1].end = end
+	} else {
+		s.pending = append(s.pending, streamSpan{offset, end})
+	}
+	return offset, nil
+}
+
+// wait blocks until the GPU is done with the fenced regions overlapping
+// [start, end). Fences complete in order, so every older fence is released too.
+func (s *StreamingBuffer) wait(start, end int) error {
+	last := -1
+	for i, f := range s.fences {
+		for _, span := range f.spans {
+			if span.overlaps(start, end) {
+				last = i
+			}
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+	sync := s.fences[last].sync
+	for {
+		switch gl.ClientWaitSync(sync, gl.SYNC_FLUSH_COMMANDS_BIT, 1e9) {
+		case gl.ALREADY_SIGNALED, gl.CONDITION_SATISFIED:
+			for _, f := range s.fences[:last+1] {
+				gl.DeleteSync(f.sync)
+			}
+			s.fences = append(s.fences[:0], s.fences[last+1:]...)
+			return nil
+		case gl.WAIT_FAILED:
+			return fmt.Errorf("streaming buffer: waiting for fence failed")
+		}
+	}
+}
+
+// Write copies data to a newly allocated region and returns its offset.
+func (s *StreamingBuffer) Write(data []byte, align int) (int, error) {
+	offset, err := s.Alloc(len(data), align)
+	if err != nil || len(data) == 0 {
+		return offset, err
+	}
+	gl.BindBuffer(s.Target, s.Buffer)
+	defer gl.BindBuffer(s.Target, 0)
+	ptr := gl.MapBufferRange(s.Target, offset, len(data),
+		gl.MAP_WRITE_BIT|gl.MAP_UNSYNCHRONIZED_BIT|gl.MAP_INVALIDATE_RANGE_BIT)
+	if ptr == nil {
+		return 0, fmt.Errorf("streaming buffer: mapping %d bytes at %d failed", len(data), offset)
+	}
+	copy(unsafe.Slice((*byte)(ptr), len(data)), data)
+	if !gl.UnmapBuffer(s.Target) {
+		return 0, fmt.Errorf("streaming buffer: buffer contents were lost while mapped")
+	}
+	return offset, nil
+}
+
+// WriteFloats is Write for float data, aligned to whole floats.
+func (s *StreamingBuffer) WriteFloats(data []float32) (int, error) {
+	if len(data) == 0 {
+		return s.Alloc(0, GL_FLOAT32_SIZE)
+	}
+	return s.Write(unsafe.Slice((*byte)(unsafe.Pointer(&data[0])), len(data)*GL_FLOAT32_SIZE), GL_FLOAT32_SIZE)
+}
+
+// EndFrame fences the regions written since the last call, once the draws
+// using them have been issued.
+func (s *StreamingBuffer) EndFrame() {
+	if len(s.pending) == 0 {
+		return
+	}
+	s.fences = append(s.fences, streamFence{
+		sync:  gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0),
+		spans: s.pending,
+	})
+	s.pending = nil
+}
+
+func (s *StreamingBuffer) Delete() {
+	for _, f := range s.fences {
+		gl.DeleteSync(f.sync)
+	}
+	s.fences = nil
+	s.pending = nil
+	gl.DeleteBuffers(1, &s.Buffer)
+}
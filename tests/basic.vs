@@ -1,10 +1,10 @@
 #version 330 core
 layout (location = 0) in vec3 position;
 layout (location = 2) in vec2 texCoord;
+layout (location = 3) in mat4 model;
 
 out vec2 TexCoord;
 
-uniform mat4 model;
 uniform mat4 view;
 uniform mat4 projection;
 
@@ -1,12 +1,12 @@
 package main
 
 import (
+	"embed"
 	"log"
 	"github.com/go-gl/glfw/v3.2/glfw"
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/raedatoui/glutils"
 	"runtime"
-	"os"
 	"github.com/go-gl/mathgl/mgl32"
 	"github.com/raedatoui/learn-opengl-golang/sections"
 )
@@ -20,15 +20,8 @@ func init() {
 	runtime.LockOSThread()
 }
 
-func init() {
-	dir, err := glutils.ImportPathToDir("github.com/raedatoui/glutils/tests")
-	if err != nil {
-		log.Fatalln("Unable to find Go package in your GOPATH, it's needed to load assets:", err)
-	}
-	if err := os.Chdir(dir); err != nil {
-		log.Panicln("os.Chdir:", err)
-	}
-}
+//go:embed basic.vs basic.frag
+var assets embed.FS
 
 func main () {
 	if err := glfw.Init(); err != nil {
@@ -111,7 +104,8 @@ func main () {
 		mgl32.Translate3D(-1.3, 1.0, -1.5),
 	}
 
-	shader, err := glutils.NewShader(
+	shader, err := glutils.NewShaderFS(
+		assets,
 		"basic.vs",
 		"basic.frag",
 		"")
@@ -124,15 +118,23 @@ func main () {
 	attr[shader.Attributes["position"]] = [2]int{3, 0}
 
 
+	// one model matrix per cube, updated every frame
+	models := make([]mgl32.Mat4, len(cubePositions))
+	instances := glutils.NewMat4InstanceBuffer(shader.Attributes["model"], models)
+
 	v := glutils.VertexArray {
 		Data: vertices,
-		DrawMode: gl.STATIC_DRAW,
+		Primitive: gl.TRIANGLES,
+		Usage: gl.STATIC_DRAW,
 		Stride: 5,
 		Attributes: attr,
 		Normalized: false,
+		Instances: []*glutils.InstanceBuffer{instances},
 	}
 
-	v.Setup()
+	if err := v.Setup(); err != nil {
+		log.Fatalf("cant setup vertex array %v", err)
+	}
 
 
 	gl.Enable(gl.BLEND)
@@ -148,32 +150,21 @@ func main () {
 		view := mgl32.Translate3D(0.0, 0.0, -3.0)
 		projection := mgl32.Perspective(45.0, sections.RATIO, 0.1, 100.0)
 
-		// Get their uniform location
-		modelLoc := shader.Uniforms["model"]
-		viewLoc := shader.Uniforms["view"]
-		projLoc := shader.Uniforms["projection"]
-
 		// Pass the matrices to the shader
-
-		gl.UniformMatrix4fv(viewLoc, 1, false, &view[0])
+		shader.SetMat4("view", view)
 		// Note: currently we set the projection matrix each frame,
 		// but since the projection matrix rarely changes it's often best practice to set it outside the main loop only once.
-		gl.UniformMatrix4fv(projLoc, 1, false, &projection[0])
-
-		// Draw container
-		gl.BindVertexArray(v.Vao)
-
-		for i := 0; i < 10; i++ {
-			// Calculate the model matrix for each object and pass it to shader before drawing
-			model := cubePositions[i]
+		shader.SetMat4("projection", projection)
 
+		// Calculate the model matrix of each container
+		for i := range models {
 			angle := float32(glfw.GetTime()) * float32(i+1)
-
-			model = model.Mul4(mgl32.HomogRotate3D(angle, rotationAxis))
-			gl.UniformMatrix4fv(modelLoc, 1, false, &model[0])
-			gl.DrawArrays(gl.TRIANGLES, 0, 36)
+			models[i] = cubePositions[i].Mul4(mgl32.HomogRotate3D(angle, rotationAxis))
 		}
-		gl.BindVertexArray(0)
+		instances.UpdateMat4(models)
+
+		// Draw all the containers at once
+		v.Draw()
 
 		window.SwapBuffers()
 		// Poll Events
@@ -5,6 +5,8 @@ import (
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"image"
 	"image/draw"
+	"io"
+	"io/fs"
 	"os"
 )
 
@@ -14,8 +16,21 @@ func ImageToPixelData(file string) (*image.RGBA, error) {
 		return nil, fmt.Errorf("texture %q not found on disk: %v", file, err)
 	}
 	defer imgFile.Close()
+	return decodePixelData(imgFile)
+}
 
-	img, _, err := image.Decode(imgFile)
+// ImageToPixelDataFS is ImageToPixelData reading the image from fsys.
+func ImageToPixelDataFS(fsys fs.FS, file string) (*image.RGBA, error) {
+	imgFile, err := fsys.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("texture %q not found: %v", file, err)
+	}
+	defer imgFile.Close()
+	return decodePixelData(imgFile)
+}
+
+func decodePixelData(r io.Reader) (*image.RGBA, error) {
+	img, _, err := image.Decode(r)
 	if err != nil {
 		return nil, err
 	}
@@ -29,8 +44,23 @@ func ImageToPixelData(file string) (*image.RGBA, error) {
 }
 
 func NewTexture(wrap_s, wrap_t, min_f, mag_f int32, file string) (uint32, error) {
-	rgba, _ := ImageToPixelData(file)
+	rgba, err := ImageToPixelData(file)
+	if err != nil {
+		return 0, err
+	}
+	return newTexture(wrap_s, wrap_t, min_f, mag_f, rgba), nil
+}
+
+// NewTextureFS is NewTexture reading the image from fsys.
+func NewTextureFS(wrap_s, wrap_t, min_f, mag_f int32, fsys fs.FS, file string) (uint32, error) {
+	rgba, err := ImageToPixelDataFS(fsys, file)
+	if err != nil {
+		return 0, err
+	}
+	return newTexture(wrap_s, wrap_t, min_f, mag_f, rgba), nil
+}
 
+func newTexture(wrap_s, wrap_t, min_f, mag_f int32, rgba *image.RGBA) uint32 {
 	var texture uint32
 	gl.GenTextures(1, &texture)
 	//gl.ActiveTexture(gl.TEXTURE0)
@@ -56,5 +86,5 @@ func NewTexture(wrap_s, wrap_t, min_f, mag_f int32, file string) (uint32, error)
 
 	gl.BindTexture(gl.TEXTURE_2D, 0)
 
-	return texture, nil
+	return texture
 }
@@ -0,0 +1,214 @@
+package glutils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// UniformBlockMember is a member of an active uniform block with the offsets
+// reported by the driver.
+type UniformBlockMember struct {
+	Name         string
+	Type         uint32
+	Size         int32
+	Offset       int32
+	ArrayStride  int32
+	MatrixStride int32
+}
+
+// UniformBlock is an active uniform block of a program.
+type UniformBlock struct {
+	Name     string
+	Index    uint32
+	DataSize int32
+	Binding  uint32
+	Members  map[string]UniformBlockMember
+}
+
+func reflectUniformBlocks(program uint32) map[string]UniformBlock {
+	blocks := make(map[string]UniformBlock)
+
+	var c int32
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORM_BLOCKS, &c)
+	for i := uint32(0); i < uint32(c); i++ {
+		var (
+			buf      [256]byte
+			size     int32
+			binding  int32
+			nMembers int32
+		)
+		gl.GetActiveUniformBlockName(program, i, 256, nil, &buf[0])
+		gl.GetActiveUniformBlockiv(program, i, gl.UNIFORM_BLOCK_DATA_SIZE, &size)
+		gl.GetActiveUniformBlockiv(program, i, gl.UNIFORM_BLOCK_BINDING, &binding)
+		gl.GetActiveUniformBlockiv(program, i, gl.UNIFORM_BLOCK_ACTIVE_UNIFORMS, &nMembers)
+
+		block := UniformBlock{
+			Name:     gl.GoStr(&buf[0]),
+			Index:    i,
+			DataSize: size,
+			Binding:  uint32(binding),
+			Members:  make(map[string]UniformBlockMember),
+		}
+		if nMembers > 0 {
+			indices := make([]int32, nMembers)
+			gl.GetActiveUniformBlockiv(program, i, gl.UNIFORM_BLOCK_ACTIVE_UNIFORM_INDICES, &indices[0])
+			uindices := make([]uint32, nMembers)
+			for j, idx := range indices {
+				uindices[j] = uint32(idx)
+			}
+
+			query := func(pname uint32) []int32 {
+				v := make([]int32, nMembers)
+				gl.GetActiveUniformsiv(program, nMembers, &uindices[0], pname, &v[0])
+				return v
+			}
+			types := query(gl.UNIFORM_TYPE)
+			sizes := query(gl.UNIFORM_SIZE)
+			offsets := query(gl.UNIFORM_OFFSET)
+			arrayStrides := query(gl.UNIFORM_ARRAY_STRIDE)
+			matrixStrides := query(gl.UNIFORM_MATRIX_STRIDE)
+
+			for j, idx := range uindices {
+				var nameBuf [256]byte
+				gl.GetActiveUniformName(program, idx, 256, nil, &nameBuf[0])
+				name := std140MemberName(block.Name, gl.GoStr(&nameBuf[0]))
+				block.Members[name] = UniformBlockMember{
+					Name:         name,
+					Type:         uint32(types[j]),
+					Size:         sizes[j],
+					Offset:       offsets[j],
+					ArrayStride:  arrayStrides[j],
+					MatrixStride: matrixStrides[j],
+				}
+			}
+		}
+		blocks[block.Name] = block
+	}
+	return blocks
+}
+
+// Verify checks that the std140 encoding of v matches the member offsets the
+// driver reported for the block. Members of the block missing from v and
+// fields of v missing from the block are reported too.
+func (b UniformBlock) Verify(v interface{}) error {
+	fields, size, err := Std140Layout(v)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		m, ok := b.Members[f.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is not a member of the block", f.Name))
+			continue
+		}
+		seen[f.Name] = true
+		if int(m.Offset) != f.Offset {
+			problems = append(problems, fmt.Sprintf("%s is at offset %d, driver expects %d", f.Name, f.Offset, m.Offset))
+		}
+		if m.ArrayStride > 0 && int(m.ArrayStride) != f.ArrayStride {
+			problems = append(problems, fmt.Sprintf("%s has array stride %d, driver expects %d", f.Name, f.ArrayStride, m.ArrayStride))
+		}
+	}
+	for name := range b.Members {
+		if !seen[name] {
+			problems = append(problems, fmt.Sprintf("%s is missing", name))
+		}
+	}
+	if size < int(b.DataSize) {
+		problems = append(problems, fmt.Sprintf("size is %d, driver expects %d", size, b.DataSize))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("uniform block %q does not match %T: %s", b.Name, v, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// BindUniformBlock assigns a uniform block of the program to a binding point.
+func (s *Shader) BindUniformBlock(name string, binding uint32) error {
+	b, ok := s.UniformBlocks[name]
+	if !ok {
+		return fmt.Errorf("uniform block %q is not an active block of program %d", name, s.Program)
+	}
+	gl.UniformBlockBinding(s.Program, b.Index, binding)
+	b.Binding = binding
+	s.UniformBlocks[name] = b
+	return nil
+}
+
+// UniformBuffer is a buffer object bound to a uniform block binding point.
+type UniformBuffer struct {
+	Ubo     uint32
+	Size    int
+	Binding uint32
+}
+
+// NewUniformBuffer allocates a uniform buffer of size bytes and binds it to
+// binding.
+func NewUniformBuffer(size int, binding uint32) *UniformBuffer {
+	u := &UniformBuffer{Size: size, Binding: binding}
+	gl.GenBuffers(1, &u.Ubo)
+	gl.BindBuffer(gl.UNIFORM_BUFFER, u.Ubo)
+	gl.BufferData(gl.UNIFORM_BUFFER, size, nil, gl.DYNAMIC_DRAW)
+	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
+	u.Bind()
+	return u
+}
+
+// NewUniformBufferFor allocates a uniform buffer sized for a block of a
+// program, binds the block and the buffer to binding, and verifies that the
+// std140 layout of v matches the block.
+func NewUniformBufferFor(s *Shader, block string, binding uint32, v interface{}) (*UniformBuffer, error) {
+	b, ok := s.UniformBlocks[block]
+	if !ok {
+		return nil, fmt.Errorf("uniform block %q is not an active block of program %d", block, s.Program)
+	}
+	if err := b.Verify(v); err != nil {
+		return nil, err
+	}
+	if err := s.BindUniformBlock(block, binding); err != nil {
+		return nil, err
+	}
+	// the encoding is padded to 16 bytes, which drivers don't always report
+	_, size, _ := Std140Layout(v)
+	if int(b.DataSize) > size {
+		size = int(b.DataSize)
+	}
+	u := NewUniformBuffer(size, binding)
+	return u, u.Set(v)
+}
+
+// Bind binds the buffer to its binding point.
+func (u *UniformBuffer) Bind() {
+	gl.BindBufferBase(gl.UNIFORM_BUFFER, u.Binding, u.Ubo)
+}
+
+// Update uploads data at the start of the buffer.
+func (u *UniformBuffer) Update(data []byte) error {
+	if len(data) > u.Size {
+		return fmt.Errorf("uniform buffer data is %d bytes, buffer is %d", len(data), u.Size)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	gl.BindBuffer(gl.UNIFORM_BUFFER, u.Ubo)
+	gl.BufferSubData(gl.UNIFORM_BUFFER, 0, len(data), gl.Ptr(data))
+	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
+	return nil
+}
+
+// Set encodes v with the std140 layout and uploads it.
+func (u *UniformBuffer) Set(v interface{}) error {
+	data, err := EncodeStd140(v)
+	if err != nil {
+		return err
+	}
+	return u.Update(data)
+}
+
+func (u *UniformBuffer) Delete() {
+	gl.DeleteBuffers(1, &u.Ubo)
+}
@@ -0,0 +1,225 @@
+package glutils
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// UniformInfo describes an active uniform as reported by glGetActiveUniform.
+// Type is the GL type enum (gl.FLOAT_MAT4, gl.SAMPLER_2D, ...) and Size is the
+// number of array elements, 1 for non-array uniforms.
+type UniformInfo struct {
+	Name     string
+	Location int32
+	Type     uint32
+	Size     int32
+}
+
+// IsArray reports whether the uniform was declared as an array.
+func (u UniformInfo) IsArray() bool {
+	return u.Size > 1
+}
+
+// Uniform returns the reflected information for a uniform.
+// Array uniforms can be looked up with or without the "[0]" suffix.
+func (s *Shader) Uniform(name string) (UniformInfo, error) {
+	u, ok := s.UniformInfos[name]
+	if !ok {
+		return u, fmt.Errorf("uniform %q is not an active uniform of program %d", name, s.Program)
+	}
+	return u, nil
+}
+
+func (s *Shader) uniformOfType(name string, types ...uint32) (UniformInfo, error) {
+	u, err := s.Uniform(name)
+	if err != nil {
+		return u, err
+	}
+	for _, t := range types {
+		if u.Type == t {
+			return u, nil
+		}
+	}
+	return u, fmt.Errorf("uniform %q is a %s, not a %s", name, UniformTypeName(u.Type), UniformTypeName(types[0]))
+}
+
+func (s *Shader) SetFloat(name string, v float32) error {
+	u, err := s.uniformOfType(name, gl.FLOAT)
+	if err != nil {
+		return err
+	}
+	gl.ProgramUniform1f(s.Program, u.Location, v)
+	return nil
+}
+
+// SetInt sets an int, bool or sampler uniform.
+func (s *Shader) SetInt(name string, v int32) error {
+	u, err := s.Uniform(name)
+	if err != nil {
+		return err
+	}
+	if u.Type != gl.INT && u.Type != gl.BOOL && !isSamplerType(u.Type) {
+		return fmt.Errorf("uniform %q is a %s, not an int", name, UniformTypeName(u.Type))
+	}
+	gl.ProgramUniform1i(s.Program, u.Location, v)
+	return nil
+}
+
+func (s *Shader) SetBool(name string, v bool) error {
+	u, err := s.uniformOfType(name, gl.BOOL)
+	if err != nil {
+		return err
+	}
+	var i int32
+	if v {
+		i = 1
+	}
+	gl.ProgramUniform1i(s.Program, u.Location, i)
+	return nil
+}
+
+func (s *Shader) SetVec2(name string, v mgl32.Vec2) error {
+	u, err := s.uniformOfType(name, gl.FLOAT_VEC2)
+	if err != nil {
+		return err
+	}
+	gl.ProgramUniform2fv(s.Program, u.Location, 1, &v[0])
+	return nil
+}
+
+func (s *Shader) SetVec3(name string, v mgl32.Vec3) error {
+	u, err := s.uniformOfType(name, gl.FLOAT_VEC3)
+	if err != nil {
+		return err
+	}
+	gl.ProgramUniform3fv(s.Program, u.Location, 1, &v[0])
+	return nil
+}
+
+func (s *Shader) SetVec4(name string, v mgl32.Vec4) error {
+	u, err := s.uniformOfType(name, gl.FLOAT_VEC4)
+	if err != nil {
+		return err
+	}
+	gl.ProgramUniform4fv(s.Program, u.Location, 1, &v[0])
+	return nil
+}
+
+func (s *Shader) SetMat3(name string, m mgl32.Mat3) error {
+	u, err := s.uniformOfType(name, gl.FLOAT_MAT3)
+	if err != nil {
+		return err
+	}
+	gl.ProgramUniformMatrix3fv(s.Program, u.Location, 1, false, &m[0])
+	return nil
+}
+
+func (s *Shader) SetMat4(name string, m mgl32.Mat4) error {
+	u, err := s.uniformOfType(name, gl.FLOAT_MAT4)
+	if err != nil {
+		return err
+	}
+	gl.ProgramUniformMatrix4fv(s.Program, u.Location, 1, false, &m[0])
+	return nil
+}
+
+// SetMat4Array sets the first elements of a mat4 array uniform, such as a bone
+// palette.
+func (s *Shader) SetMat4Array(name string, m []mgl32.Mat4) error {
+	u, err := s.uniformOfType(name, gl.FLOAT_MAT4)
+	if err != nil {
+		return err
+	}
+	if int32(len(m)) > u.Size {
+		return fmt.Errorf("uniform %q has %d elements, got %d matrices", name, u.Size, len(m))
+	}
+	if len(m) > 0 {
+		gl.ProgramUniformMatrix4fv(s.Program, u.Location, int32(len(m)), false, &m[0][0])
+	}
+	return nil
+}
+
+// SetColor sets a vec3 or vec4 uniform from a Color.
+// The alpha channel is dropped for vec3 uniforms.
+func (s *Shader) SetColor(name string, c Color) error {
+	u, err := s.uniformOfType(name, gl.FLOAT_VEC4, gl.FLOAT_VEC3)
+	if err != nil {
+		return err
+	}
+	c32 := c.To32()
+	if u.Type == gl.FLOAT_VEC3 {
+		gl.ProgramUniform3f(s.Program, u.Location, c32.R, c32.G, c32.B)
+	} else {
+		gl.ProgramUniform4f(s.Program, u.Location, c32.R, c32.G, c32.B, c32.A)
+	}
+	return nil
+}
+
+func isSamplerType(t uint32) bool {
+	switch t {
+	case gl.SAMPLER_1D, gl.SAMPLER_2D, gl.SAMPLER_3D, gl.SAMPLER_CUBE,
+		gl.SAMPLER_1D_SHADOW, gl.SAMPLER_2D_SHADOW, gl.SAMPLER_CUBE_SHADOW,
+		gl.SAMPLER_1D_ARRAY, gl.SAMPLER_2D_ARRAY, gl.SAMPLER_1D_ARRAY_SHADOW, gl.SAMPLER_2D_ARRAY_SHADOW,
+		gl.SAMPLER_2D_MULTISAMPLE, gl.SAMPLER_2D_MULTISAMPLE_ARRAY, gl.SAMPLER_BUFFER,
+		gl.SAMPLER_2D_RECT, gl.SAMPLER_2D_RECT_SHADOW, gl.SAMPLER_CUBE_MAP_ARRAY, gl.SAMPLER_CUBE_MAP_ARRAY_SHADOW,
+		gl.INT_SAMPLER_1D, gl.INT_SAMPLER_2D, gl.INT_SAMPLER_3D, gl.INT_SAMPLER_CUBE,
+		gl.INT_SAMPLER_1D_ARRAY, gl.INT_SAMPLER_2D_ARRAY, gl.INT_SAMPLER_2D_MULTISAMPLE,
+		gl.INT_SAMPLER_2D_MULTISAMPLE_ARRAY, gl.INT_SAMPLER_BUFFER, gl.INT_SAMPLER_2D_RECT,
+		gl.UNSIGNED_INT_SAMPLER_1D, gl.UNSIGNED_INT_SAMPLER_2D, gl.UNSIGNED_INT_SAMPLER_3D,
+		gl.UNSIGNED_INT_SAMPLER_CUBE, gl.UNSIGNED_INT_SAMPLER_1D_ARRAY, gl.UNSIGNED_INT_SAMPLER_2D_ARRAY,
+		gl.UNSIGNED_INT_SAMPLER_2D_MULTISAMPLE, gl.UNSIGNED_INT_SAMPLER_2D_MULTISAMPLE_ARRAY,
+		gl.UNSIGNED_INT_SAMPLER_BUFFER, gl.UNSIGNED_INT_SAMPLER_2D_RECT:
+		return true
+	}
+	return false
+}
+
+// UniformTypeName returns the GLSL name of a uniform type enum.
+func UniformTypeName(t uint32) string {
+	switch t {
+	case gl.FLOAT:
+		return "float"
+	case gl.FLOAT_VEC2:
+		return "vec2"
+	case gl.FLOAT_VEC3:
+		return "vec3"
+	case gl.FLOAT_VEC4:
+		return "vec4"
+	case gl.INT:
+		return "int"
+	case gl.INT_VEC2:
+		return "ivec2"
+	case gl.INT_VEC3:
+		return "ivec3"
+	case gl.INT_VEC4:
+		return "ivec4"
+	case gl.UNSIGNED_INT:
+		return "uint"
+	case gl.UNSIGNED_INT_VEC2:
+		return "uvec2"
+	case gl.UNSIGNED_INT_VEC3:
+		return "uvec3"
+	case gl.UNSIGNED_INT_VEC4:
+		return "uvec4"
+	case gl.BOOL:
+		return "bool"
+	case gl.FLOAT_MAT2:
+		return "mat2"
+	case gl.FLOAT_MAT3:
+		return "mat3"
+	case gl.FLOAT_MAT4:
+		return "mat4"
+	case gl.SAMPLER_2D:
+		return "sampler2D"
+	case gl.SAMPLER_3D:
+		return "sampler3D"
+	case gl.SAMPLER_CUBE:
+		return "samplerCube"
+	}
+	if isSamplerType(t) {
+		return "sampler"
+	}
+	return fmt.Sprintf("0x%x", t)
+}
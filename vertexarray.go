@@ -0,0 +1,366 @@
+package glutils
+
+import (
+	"fmt"
+	"math"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// VertexArray is a VAO with its vertex and index buffers.
+// The vertex buffer holds either Data, described by Attributes in float
+// units, or RawData, described by Layout in bytes for compact formats.
+// Primitive is the type of primitive drawn, gl.TRIANGLES when left unset.
+// gl.POINTS is 0 and can't be told from unset, use SetPrimitive to draw
+// points. Usage is the buffer usage hint, gl.STATIC_DRAW when 0, which no
+// hint is.
+// IndexType is the type of the uploaded indices, set by Setup to the smallest
+// type holding every index unless already set.
+// Instances are per-instance attribute buffers; when present Draw draws one
+// instance per element of the shortest buffer.
+type VertexArray struct {
+	Data       []float32
+	RawData    []byte
+	Indices    []uint32
+	IndexType  uint32
+	Stride     int32
+	Normalized bool
+	Primitive  uint32
+	Usage      uint32
+	// Deprecated: DrawMode is the buffer usage hint, use Usage.
+	DrawMode      uint32
+	Attributes    AttributesMap
+	Layout        *VertexLayout
+	Instances     []*InstanceBuffer
+	Vao, Vbo, Ebo uint32
+	vboSize       int
+	primitiveSet  bool
+}
+
+// Setup creates the buffers and the VAO. It returns an error without making
+// any GL call when the vertex layout is invalid, see VertexLayout.Validate.
+func (v *VertexArray) Setup() error {
+	layout := v.VertexLayout()
+	if err := layout.Validate(); err != nil {
+		return fmt.Errorf("vertex array: %v", err)
+	}
+	gl.GenVertexArrays(1, &v.Vao)
+	fillVbo := true
+	// Vbo already set when VertexArray was instancied.
+	// This is a secondary structure using the same Vbo and vertex
+	// data but with a different shader and attributes
+	if v.Vbo == 0 {
+		gl.GenBuffers(1, &v.Vbo)
+	} else {
+		fillVbo = false
+	}
+	if len(v.Indices) > 0 {
+		gl.GenBuffers(1, &v.Ebo)
+	}
+
+	gl.BindVertexArray(v.Vao)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, v.Vbo)
+	if fillVbo {
+		size, ptr := v.vertexData()
+		gl.BufferData(gl.ARRAY_BUFFER, size, ptr, v.usage())
+		v.vboSize = size
+	}
+
+	if len(v.Indices) > 0 {
+		if v.IndexType == 0 {
+			v.IndexType = IndexType(v.Indices)
+		}
+		size, ptr := packIndices(v.Indices, v.IndexType)
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, v.Ebo)
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, size, ptr, v.usage())
+	}
+
+	layout.apply()
+	for _, b := range v.Instances {
+		b.attach()
+	}
+	gl.BindVertexArray(0)
+	return nil
+}
+
+// AddInstances attaches per-instance attribute buffers, after Setup or before.
+func (v *VertexArray) AddInstances(buffers ...*InstanceBuffer) {
+	v.Instances = append(v.Instances, buffers...)
+	if v.Vao == 0 {
+		return
+	}
+	for _, b := range buffers {
+		b.Attach(v.Vao)
+	}
+}
+
+// InstanceCount returns the number of instances Draw draws, 0 when there are
+// no instance buffers.
+func (v *VertexArray) InstanceCount() int32 {
+	return instanceCount(v.Instances)
+}
+
+// VertexLayout returns Layout, or the float layout described by Attributes,
+// Stride and Normalized when Layout is nil.
+func (v *VertexArray) VertexLayout() *VertexLayout {
+	if v.Layout != nil {
+		return v.Layout
+	}
+	return v.Attributes.Layout(v.Stride, v.Normalized)
+}
+
+// vertexData returns the size in bytes and a pointer to RawData or Data.
+func (v *VertexArray) vertexData() (int, unsafe.Pointer) {
+	if v.RawData != nil {
+		if len(v.RawData) == 0 {
+			return 0, nil
+		}
+		return len(v.RawData), gl.Ptr(v.RawData)
+	}
+	if len(v.Data) == 0 {
+		return 0, nil
+	}
+	return len(v.Data) * GL_FLOAT32_SIZE, gl.Ptr(v.Data)
+}
+
+// Update replaces Data and uploads it. The buffer is only reallocated when
+// data doesn't fit in it.
+func (v *VertexArray) Update(data []float32) {
+	v.Data = data
+	v.upload(false)
+}
+
+// UpdateRaw replaces RawData and uploads it.
+func (v *VertexArray) UpdateRaw(data []byte) {
+	v.RawData = data
+	v.upload(false)
+}
+
+// Orphan replaces Data and uploads it to new storage. The driver hands out
+// fresh memory while draws still using the previous contents complete,
+// instead of stalling until they are done. Use it for data rewritten every
+// frame, with Usage set to gl.STREAM_DRAW or gl.DYNAMIC_DRAW.
+func (v *VertexArray) Orphan(data []float32) {
+	v.Data = data
+	v.upload(true)
+}
+
+// OrphanRaw is Orphan for RawData.
+func (v *VertexArray) OrphanRaw(data []byte) {
+	v.RawData = data
+	v.upload(true)
+}
+
+func (v *VertexArray) upload(orphan bool) {
+	size, ptr := v.vertexData()
+	gl.BindBuffer(gl.ARRAY_BUFFER, v.Vbo)
+	switch {
+	case size > v.vboSize:
+		gl.BufferData(gl.ARRAY_BUFFER, size, ptr, v.usage())
+		v.vboSize = size
+	case orphan:
+		gl.BufferData(gl.ARRAY_BUFFER, v.vboSize, nil, v.usage())
+		fallthrough
+	case size > 0:
+		gl.BufferSubData(gl.ARRAY_BUFFER, 0, size, ptr)
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+}
+
+// UpdateRange overwrites part of the vertex buffer, and of Data, starting at
+// offset floats.
+func (v *VertexArray) UpdateRange(offset int, data []float32) error {
+	if offset < 0 || offset+len(data) > len(v.Data) {
+		return fmt.Errorf("update range [%d, %d) out of the %d floats of data", offset, offset+len(data), len(v.Data))
+	}
+	copy(v.Data[offset:], data)
+	if len(data) == 0 {
+		return nil
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, v.Vbo)
+	gl.BufferSubData(gl.ARRAY_BUFFER, offset*GL_FLOAT32_SIZE, len(data)*GL_FLOAT32_SIZE, gl.Ptr(data))
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	return nil
+}
+
+// UpdateRawRange overwrites part of the vertex buffer, and of RawData,
+// starting at offset bytes.
+func (v *VertexArray) UpdateRawRange(offset int, data []byte) error {
+	if offset < 0 || offset+len(data) > len(v.RawData) {
+		return fmt.Errorf("update range [%d, %d) out of the %d bytes of data", offset, offset+len(data), len(v.RawData))
+	}
+	copy(v.RawData[offset:], data)
+	if len(data) == 0 {
+		return nil
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, v.Vbo)
+	gl.BufferSubData(gl.ARRAY_BUFFER, offset, len(data), gl.Ptr(data))
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	return nil
+}
+
+func (v *VertexArray) usage() uint32 {
+	switch {
+	case v.Usage != 0:
+		return v.Usage
+	case v.DrawMode != 0:
+		return v.DrawMode
+	}
+	return gl.STATIC_DRAW
+}
+
+// SetPrimitive sets the type of primitive drawn, gl.POINTS included.
+func (v *VertexArray) SetPrimitive(mode uint32) {
+	v.Primitive, v.primitiveSet = mode, true
+}
+
+func (v *VertexArray) primitive() uint32 {
+	if v.primitiveSet || v.Primitive != 0 {
+		return v.Primitive
+	}
+	return gl.TRIANGLES
+}
+
+func (v *VertexArray) indexType() uint32 {
+	if v.IndexType != 0 {
+		return v.IndexType
+	}
+	return gl.UNSIGNED_INT
+}
+
+// IndexType returns the smallest of gl.UNSIGNED_BYTE, gl.UNSIGNED_SHORT and
+// gl.UNSIGNED_INT holding every index.
+func IndexType(indices []uint32) uint32 {
+	var max uint32
+	for _, i := range indices {
+		if i > max {
+			max = i
+		}
+	}
+	switch {
+	case max <= math.MaxUint8:
+		return gl.UNSIGNED_BYTE
+	case max <= math.MaxUint16:
+		return gl.UNSIGNED_SHORT
+	}
+	return gl.UNSIGNED_INT
+}
+
+// packIndices converts indices to xtype and returns their size in bytes and a
+// pointer to them.
+func packIndices(indices []uint32, xtype uint32) (int, unsafe.Pointer) {
+	if len(indices) == 0 {
+		return 0, nil
+	}
+	switch xtype {
+	case gl.UNSIGNED_BYTE:
+		packed := make([]uint8, len(indices))
+		for i, idx := range indices {
+			packed[i] = uint8(idx)
+		}
+		return len(packed), gl.Ptr(packed)
+	case gl.UNSIGNED_SHORT:
+		packed := make([]uint16, len(indices))
+		for i, idx := range indices {
+			packed[i] = uint16(idx)
+		}
+		return len(packed) * 2, gl.Ptr(packed)
+	}
+	return len(indices) * 4, gl.Ptr(indices)
+}
+
+// VertexCount returns the number of vertices in the vertex buffer, Data or
+// RawData divided by the stride of Layout when set, Data by Stride otherwise.
+func (v *VertexArray) VertexCount() int32 {
+	if v.Layout != nil {
+		if v.Layout.Stride == 0 {
+			return 0
+		}
+		size := len(v.RawData)
+		if v.RawData == nil {
+			size = len(v.Data) * 4
+		}
+		return int32(size / v.Layout.Stride)
+	}
+	if v.RawData != nil || v.Stride == 0 {
+		return 0
+	}
+	return int32(len(v.Data)) / v.Stride
+}
+
+// Count returns the number of elements drawn by Draw: the number of indices
+// when there are any, the number of vertices otherwise.
+func (v *VertexArray) Count() int32 {
+	if len(v.Indices) > 0 {
+		return int32(len(v.Indices))
+	}
+	return v.VertexCount()
+}
+
+// Draw draws every vertex, or every index when Indices are present, once per
+// instance when there are instance buffers.
+func (v *VertexArray) Draw() {
+	if len(v.Instances) > 0 {
+		v.DrawInstanced(v.InstanceCount())
+		return
+	}
+	v.DrawRange(0, v.Count())
+}
+
+// DrawRange draws count elements starting at first. Elements are indices
+// when Indices are present, vertices otherwise.
+func (v *VertexArray) DrawRange(first, count int32) {
+	gl.BindVertexArray(v.Vao)
+	if len(v.Indices) > 0 {
+		gl.DrawElements(v.primitive(), count, v.indexType(), gl.PtrOffset(int(first)*ComponentSize(v.indexType())))
+	} else {
+		gl.DrawArrays(v.primitive(), first, count)
+	}
+	gl.BindVertexArray(0)
+}
+
+// DrawInstanced draws every element instances times.
+func (v *VertexArray) DrawInstanced(instances int32) {
+	gl.BindVertexArray(v.Vao)
+	if len(v.Indices) > 0 {
+		gl.DrawElementsInstanced(v.primitive(), v.Count(), v.indexType(), gl.PtrOffset(0), instances)
+	} else {
+		gl.DrawArraysInstanced(v.primitive(), 0, v.Count(), instances)
+	}
+	gl.BindVertexArray(0)
+}
+
+func (v *VertexArray) Delete() {
+	gl.DeleteVertexArrays(1, &v.Vao)
+	gl.DeleteBuffers(1, &v.Vbo)
+	if len(v.Indices) > 0 {
+		gl.DeleteBuffers(1, &v.Ebo)
+	}
+}
+
+type AttributesMap map[uint32][2]int //map attrib loc to size / offset
+
+func NewAttributesMap() AttributesMap {
+	return make(AttributesMap)
+}
+func (am AttributesMap) Add(k uint32, size, offset int) {
+	am[k] = [2]int{size, offset}
+}
+
+// Layout converts the map to a VertexLayout of float attributes.
+// stride is in floats, like the sizes and offsets of the map.
+func (am AttributesMap) Layout(stride int32, normalized bool) *VertexLayout {
+	l := &VertexLayout{Stride: int(stride) * GL_FLOAT32_SIZE}
+	for loc, ss := range am {
+		l.Attributes = append(l.Attributes, VertexAttribute{
+			Location:   loc,
+			Size:       int32(ss[0]),
+			Type:       gl.FLOAT,
+			Normalized: normalized,
+			Offset:     ss[1] * GL_FLOAT32_SIZE,
+		})
+	}
+	return l
+}
@@ -0,0 +1,28 @@
+package glutils
+
+import "testing"
+
+func TestVertexArrayCount(t *testing.T) {
+	layout := &VertexLayout{Stride: 12}
+	tests := []struct {
+		name     string
+		va       VertexArray
+		vertices int32
+		count    int32
+	}{
+		{"float data", VertexArray{Data: make([]float32, 12), Stride: 3}, 4, 4},
+		{"float data with a layout", VertexArray{Data: make([]float32, 12), Layout: layout}, 4, 4},
+		{"raw data", VertexArray{RawData: make([]byte, 36), Layout: layout}, 3, 3},
+		{"raw data without a layout", VertexArray{RawData: make([]byte, 36)}, 0, 0},
+		{"no stride", VertexArray{Data: make([]float32, 12)}, 0, 0},
+		{"indexed", VertexArray{Data: make([]float32, 12), Layout: layout, Indices: []uint32{0, 1, 2, 0, 2, 3}}, 4, 6},
+	}
+	for _, tt := range tests {
+		if got := tt.va.VertexCount(); got != tt.vertices {
+			t.Errorf("%s: VertexCount() = %d, want %d", tt.name, got, tt.vertices)
+		}
+		if got := tt.va.Count(); got != tt.count {
+			t.Errorf("%s: Count() = %d, want %d", tt.name, got, tt.count)
+		}
+	}
+}
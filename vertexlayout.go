@@ -0,0 +1,206 @@
+package glutils
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// VertexAttribute describes one attribute of an interleaved vertex buffer.
+// Type is the GL component type: gl.FLOAT, gl.HALF_FLOAT, gl.BYTE,
+// gl.UNSIGNED_BYTE, gl.SHORT, gl.UNSIGNED_SHORT, gl.INT, gl.UNSIGNED_INT,
+// gl.INT_2_10_10_10_REV or gl.UNSIGNED_INT_2_10_10_10_REV.
+// Integer attributes are bound with glVertexAttribIPointer and read as
+// int/ivec/uint/uvec in the shader; all others are converted to floats,
+// mapped to [0, 1] or [-1, 1] when Normalized is set.
+// Offset is in bytes from the start of the vertex.
+type VertexAttribute struct {
+	Location   uint32
+	Size       int32
+	Type       uint32
+	Normalized bool
+	Integer    bool
+	Offset     int
+}
+
+// VertexLayout describes the attributes of an interleaved vertex buffer.
+// Stride is the size of a vertex in bytes.
+type VertexLayout struct {
+	Stride     int
+	Attributes []VertexAttribute
+}
+
+func NewVertexLayout() *VertexLayout {
+	return &VertexLayout{}
+}
+
+// Float appends a float attribute after the previous ones.
+// Use gl.FLOAT or gl.HALF_FLOAT, or an integer type with normalized set,
+// e.g. gl.UNSIGNED_BYTE colors.
+func (l *VertexLayout) Float(loc uint32, size int32, xtype uint32, normalized bool) *VertexLayout {
+	return l.append(VertexAttribute{Location: loc, Size: size, Type: xtype, Normalized: normalized})
+}
+
+// Int appends an integer attribute after the previous ones.
+func (l *VertexLayout) Int(loc uint32, size int32, xtype uint32) *VertexLayout {
+	return l.append(VertexAttribute{Location: loc, Size: size, Type: xtype, Integer: true})
+}
+
+// Packed appends a 2_10_10_10 attribute after the previous ones, typically a
+// normal or tangent packed with PackInt2101010.
+func (l *VertexLayout) Packed(loc uint32, xtype uint32, normalized bool) *VertexLayout {
+	return l.append(VertexAttribute{Location: loc, Size: 4, Type: xtype, Normalized: normalized})
+}
+
+// Mat4 appends a mat4 attribute after the previous ones. It takes the four
+// locations from loc to loc+3, one vec4 per column.
+func (l *VertexLayout) Mat4(loc uint32) *VertexLayout {
+	for c := uint32(0); c < 4; c++ {
+		l.Float(loc+c, 4, gl.FLOAT, false)
+	}
+	return l
+}
+
+func (l *VertexLayout) append(a VertexAttribute) *VertexLayout {
+	// keep every attribute 4 byte aligned, as recommended by all vendors
+	a.Offset = roundUp(l.Stride, 4)
+	l.Attributes = append(l.Attributes, a)
+	l.Stride = roundUp(a.Offset+a.ByteSize(), 4)
+	return l
+}
+
+// ByteSize returns the number of bytes the attribute takes in a vertex.
+func (a VertexAttribute) ByteSize() int {
+	switch a.Type {
+	case gl.INT_2_10_10_10_REV, gl.UNSIGNED_INT_2_10_10_10_REV:
+		return 4
+	}
+	return int(a.Size) * ComponentSize(a.Type)
+}
+
+// ComponentSize returns the size in bytes of a GL component type.
+func ComponentSize(xtype uint32) int {
+	switch xtype {
+	case gl.BYTE, gl.UNSIGNED_BYTE:
+		return 1
+	case gl.SHORT, gl.UNSIGNED_SHORT, gl.HALF_FLOAT:
+		return 2
+	case gl.INT, gl.UNSIGNED_INT, gl.FLOAT, gl.INT_2_10_10_10_REV, gl.UNSIGNED_INT_2_10_10_10_REV:
+		return 4
+	case gl.DOUBLE:
+		return 8
+	}
+	return 0
+}
+
+// Validate checks the attributes against the rules of glVertexAttribPointer,
+// that they fit in the stride and don't share a location. Attributes may
+// read the same bytes, GL allows such aliasing.
+func (l *VertexLayout) Validate() error {
+	for _, a := range l.Attributes {
+		size := ComponentSize(a.Type)
+		switch {
+		case size == 0:
+			return fmt.Errorf("attribute %d: unsupported component type 0x%x", a.Location, a.Type)
+		case a.Size < 1 || a.Size > 4:
+			return fmt.Errorf("attribute %d: size must be 1 to 4, got %d", a.Location, a.Size)
+		case a.Integer && (a.Type == gl.FLOAT || a.Type == gl.HALF_FLOAT || a.Type == gl.DOUBLE ||
+			a.Type == gl.INT_2_10_10_10_REV || a.Type == gl.UNSIGNED_INT_2_10_10_10_REV):
+			return fmt.Errorf("attribute %d: integer attributes need an integer component type", a.Location)
+		case (a.Type == gl.INT_2_10_10_10_REV || a.Type == gl.UNSIGNED_INT_2_10_10_10_REV) && a.Size != 4:
+			return fmt.Errorf("attribute %d: packed 2_10_10_10 attributes must have size 4", a.Location)
+		case a.Offset+a.ByteSize() > l.Stride && l.Stride > 0:
+			return fmt.Errorf("attribute %d: ends at byte %d past the stride %d", a.Location, a.Offset+a.ByteSize(), l.Stride)
+		}
+	}
+	for i, a := range l.Attributes {
+		for _, b := range l.Attributes[:i] {
+			if a.Location == b.Location {
+				return fmt.Errorf("attribute %d: location used twice", a.Location)
+			}
+		}
+	}
+	return nil
+}
+
+// apply enables and describes the attributes of the bound vertex buffer on
+// the bound VAO.
+func (l *VertexLayout) apply() {
+	for _, a := range l.Attributes {
+		gl.EnableVertexAttribArray(a.Location)
+		if a.Integer {
+			gl.VertexAttribIPointer(a.Location, a.Size, a.Type, int32(l.Stride), gl.PtrOffset(a.Offset))
+		} else {
+			gl.VertexAttribPointer(a.Location, a.Size, a.Type, a.Normalized, int32(l.Stride), gl.PtrOffset(a.Offset))
+		}
+	}
+}
+
+// PackInt2101010 packs a vector with components in [-1, 1], such as a normal,
+// into the gl.INT_2_10_10_10_REV format. w is stored in the 2 high bits.
+func PackInt2101010(v mgl32.Vec4) uint32 {
+	pack := func(f float32, bits uint) uint32 {
+		max := float32(int32(1)<<(bits-1) - 1)
+		f = mgl32.Clamp(f, -1, 1)
+		i := int32(math.Round(float64(f * max)))
+		return uint32(i) & (1<<bits - 1)
+	}
+	return pack(v[0], 10) | pack(v[1], 10)<<10 | pack(v[2], 10)<<20 | pack(v[3], 2)<<30
+}
+
+// PackUint2101010 packs a vector with components in [0, 1] into the
+// gl.UNSIGNED_INT_2_10_10_10_REV format.
+func PackUint2101010(v mgl32.Vec4) uint32 {
+	pack := func(f float32, bits uint) uint32 {
+		max := float32(uint32(1)<<bits - 1)
+		f = mgl32.Clamp(f, 0, 1)
+		return uint32(math.Round(float64(f * max)))
+	}
+	return pack(v[0], 10) | pack(v[1], 10)<<10 | pack(v[2], 10)<<20 | pack(v[3], 2)<<30
+}
+
+// Float32ToHalf converts f to the IEEE 754 half precision bits used by
+// gl.HALF_FLOAT attributes, rounding to nearest even.
+func Float32ToHalf(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23&0xff) - 127 + 15
+	mant := bits & 0x7fffff
+
+	switch {
+	case bits&0x7fffffff == 0:
+		return sign
+	case bits>>23&0xff == 0xff:
+		// inf or NaN
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exp >= 0x1f:
+		// overflow to infinity
+		return sign | 0x7c00
+	case exp <= 0:
+		// subnormal half or zero
+		if exp < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint32(14 - exp)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		mid := uint32(1) << (shift - 1)
+		if rem > mid || rem == mid && half&1 == 1 {
+			half++
+		}
+		return sign | uint16(half)
+	}
+	half := uint32(exp)<<10 | mant>>13
+	rem := mant & 0x1fff
+	if rem > 0x1000 || rem == 0x1000 && half&1 == 1 {
+		// may carry into the exponent, which is the correct rounding
+		half++
+	}
+	return sign | uint16(half)
+}
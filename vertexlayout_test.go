@@ -0,0 +1,40 @@
+package glutils
+
+import (
+	"testing"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+func TestVertexLayoutValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs []VertexAttribute
+		ok    bool
+	}{
+		{"interleaved", []VertexAttribute{
+			{Location: 0, Size: 3, Type: gl.FLOAT},
+			{Location: 1, Size: 2, Type: gl.FLOAT, Offset: 12},
+		}, true},
+		{"aliased packed and unpacked", []VertexAttribute{
+			{Location: 0, Size: 4, Type: gl.UNSIGNED_BYTE, Normalized: true, Offset: 16},
+			{Location: 1, Size: 1, Type: gl.UNSIGNED_INT, Integer: true, Offset: 16},
+		}, true},
+		{"duplicate location", []VertexAttribute{
+			{Location: 0, Size: 3, Type: gl.FLOAT},
+			{Location: 0, Size: 2, Type: gl.FLOAT, Offset: 12},
+		}, false},
+		{"past the stride", []VertexAttribute{
+			{Location: 0, Size: 4, Type: gl.FLOAT, Offset: 8},
+		}, false},
+		{"integer float", []VertexAttribute{
+			{Location: 0, Size: 1, Type: gl.FLOAT, Integer: true},
+		}, false},
+	}
+	for _, tt := range tests {
+		l := &VertexLayout{Stride: 20, Attributes: tt.attrs}
+		if err := l.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+	}
+}